@@ -0,0 +1,163 @@
+package arcgis2geojson
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// SplitAntimeridian splits lines and polygons crossing the antimeridian at 180°, as RFC 7946 asks,
+// into multilinestrings and multipolygons. a segment crosses the antimeridian when the longitudes of
+// its ends are more than 180° apart. rings around a pole, and geometries with longitudes outside
+// -180..180, are left as they are.
+func SplitAntimeridian(g orb.Geometry) orb.Geometry {
+	if g == nil {
+		return g
+	}
+	if b := g.Bound(); !(b.Min[0] >= -180 && b.Max[0] <= 180) {
+		return g
+	}
+	switch t := g.(type) {
+	case orb.LineString:
+		return multiLineString(splitLineString(t))
+	case orb.MultiLineString:
+		lines := orb.MultiLineString{}
+		for _, ls := range t {
+			lines = append(lines, splitLineString(ls)...)
+		}
+		return multiLineString(lines)
+	case orb.Polygon:
+		return multiPolygon(splitPolygon(t))
+	case orb.MultiPolygon:
+		polygons := orb.MultiPolygon{}
+		for _, p := range t {
+			polygons = append(polygons, splitPolygon(p)...)
+		}
+		return multiPolygon(polygons)
+	}
+	return g
+}
+
+// unwrapPath shifts the longitudes of a path by multiples of 360° so no segment is longer than 180°,
+// and reports whether it had to
+func unwrapPath(path []orb.Point) ([]orb.Point, bool) {
+	unwrapped := make([]orb.Point, len(path))
+	offset := 0.0
+	crosses := false
+	for i, pt := range path {
+		if i > 0 {
+			if d := pt[0] - path[i-1][0]; d > 180 {
+				offset -= 360
+				crosses = true
+			} else if d < -180 {
+				offset += 360
+				crosses = true
+			}
+		}
+		unwrapped[i] = orb.Point{pt[0] + offset, pt[1]}
+	}
+	return unwrapped, crosses
+}
+
+func splitLineString(ls orb.LineString) orb.MultiLineString {
+	unwrapped, crosses := unwrapPath(ls)
+	if !crosses {
+		return orb.MultiLineString{ls}
+	}
+	lines := orb.MultiLineString{}
+	for _, g := range splitWorlds(orb.LineString(unwrapped)) {
+		switch t := g.(type) {
+		case orb.LineString:
+			lines = append(lines, t)
+		case orb.MultiLineString:
+			lines = append(lines, t...)
+		}
+	}
+	return lines
+}
+
+func splitPolygon(p orb.Polygon) orb.MultiPolygon {
+	if len(p) == 0 {
+		return orb.MultiPolygon{p}
+	}
+	shell, crosses := unwrapPath(p[0])
+	if !crosses {
+		return orb.MultiPolygon{p}
+	}
+	if shell[0] != shell[len(shell)-1] {
+		// the ring goes around a pole
+		return orb.MultiPolygon{p}
+	}
+	unwrapped := orb.Polygon{orb.Ring(shell)}
+	center := unwrapped.Bound().Center()[0]
+	for _, r := range p[1:] {
+		hole, _ := unwrapPath(r)
+		// move the hole to the copy of the world the shell was unwrapped into
+		shift := 360 * math.Round((center-orb.Ring(hole).Bound().Center()[0])/360)
+		for i := range hole {
+			hole[i][0] += shift
+		}
+		unwrapped = append(unwrapped, orb.Ring(hole))
+	}
+	polygons := orb.MultiPolygon{}
+	for _, g := range splitWorlds(unwrapped) {
+		switch t := g.(type) {
+		case orb.Polygon:
+			polygons = append(polygons, t)
+		case orb.MultiPolygon:
+			polygons = append(polygons, t...)
+		}
+	}
+	return polygons
+}
+
+// splitWorlds clips an unwrapped geometry to each copy of the world it covers, moving each part back
+// between -180° and 180°
+func splitWorlds(g orb.Geometry) []orb.Geometry {
+	b := g.Bound()
+	parts := []orb.Geometry{}
+	for w := math.Floor((b.Min[0] + 180) / 360); w <= math.Floor((b.Max[0]+180)/360); w++ {
+		west, east := -180+360*w, 180+360*w
+		if b.Max[0] <= west || b.Min[0] >= east {
+			continue
+		}
+		world := orb.Bound{Min: orb.Point{west, b.Min[1]}, Max: orb.Point{east, b.Max[1]}}
+		part, _ := ClipGeometry(orb.Clone(g), world)
+		if part == nil {
+			continue
+		}
+		parts = append(parts, shiftLongitude(part, -360*w))
+	}
+	return parts
+}
+
+// shiftLongitude adds d to the longitude of every point of g
+func shiftLongitude(g orb.Geometry, d float64) orb.Geometry {
+	if d == 0 {
+		return g
+	}
+	shift := func(points []orb.Point) {
+		for i := range points {
+			points[i][0] += d
+		}
+	}
+	switch t := g.(type) {
+	case orb.LineString:
+		shift(t)
+	case orb.MultiLineString:
+		for _, ls := range t {
+			shift(ls)
+		}
+	case orb.Polygon:
+		for _, r := range t {
+			shift(r)
+		}
+	case orb.MultiPolygon:
+		for _, p := range t {
+			for _, r := range p {
+				shift(r)
+			}
+		}
+	}
+	return g
+}
@@ -0,0 +1,82 @@
+package arcgis2geojson
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+func TestSplitAntimeridian(t *testing.T) {
+	ls := orb.LineString{{170, 0}, {-170, 10}, {-160, 10}}
+	expected := orb.MultiLineString{{{170, 0}, {180, 5}}, {{-180, 5}, {-170, 10}, {-160, 10}}}
+	if g := SplitAntimeridian(ls); !reflect.DeepEqual(g, expected) {
+		t.Errorf("expected %v, got %v", expected, g)
+	}
+	if g := SplitAntimeridian(orb.LineString{{-170, 0}, {170, 0}, {-170, 0}}); len(g.(orb.MultiLineString)) != 3 {
+		t.Errorf("expected a line crossing back and forth to split in 3, got %v", g)
+	}
+	straight := orb.LineString{{-170, 0}, {0, 0}, {170, 0}}
+	if g := SplitAntimeridian(straight); !reflect.DeepEqual(g, straight) {
+		t.Errorf("expected a line not crossing to stay the same, got %v", g)
+	}
+
+	shell := orb.Ring{{170, 0}, {-170, 0}, {-170, 10}, {170, 10}, {170, 0}}
+	tests := []struct {
+		name  string
+		hole  orb.Ring
+		rings []int
+		area  float64
+	}{
+		// the hole crosses too and becomes a notch in both halves
+		{"crossing hole", orb.Ring{{178, 4}, {178, 6}, {-178, 6}, {-178, 4}, {178, 4}}, []int{1, 1}, 192},
+		{"eastern hole", orb.Ring{{172, 4}, {172, 6}, {174, 6}, {174, 4}, {172, 4}}, []int{2, 1}, 196},
+		{"western hole", orb.Ring{{-174, 4}, {-174, 6}, {-172, 6}, {-172, 4}, {-174, 4}}, []int{1, 2}, 196},
+	}
+	for _, test := range tests {
+		g := SplitAntimeridian(orb.Polygon{shell.Clone(), test.hole})
+		mp, ok := g.(orb.MultiPolygon)
+		if !ok || len(mp) != 2 {
+			t.Errorf("%s: expected a multipolygon of 2 polygons, got %v", test.name, g)
+			continue
+		}
+		for i, p := range mp {
+			b := p.Bound()
+			if b.Min[0] < -180 || b.Max[0] > 180 {
+				t.Errorf("%s: polygon %d crosses the antimeridian: %v", test.name, i, b)
+			}
+		}
+		// the eastern half comes first
+		if mp[0].Bound().Min[0] < 0 {
+			mp[0], mp[1] = mp[1], mp[0]
+		}
+		if len(mp[0]) != test.rings[0] || len(mp[1]) != test.rings[1] {
+			t.Errorf("%s: expected %v rings, got %d and %d", test.name, test.rings, len(mp[0]), len(mp[1]))
+		}
+		if area := planar.Area(mp); math.Abs(area-test.area) > 1e-9 {
+			t.Errorf("%s: expected area %v, got %v", test.name, test.area, area)
+		}
+	}
+}
+
+func TestConvertSplitAntimeridian(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"paths": [[[170, 50], [-170, 60]]]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"rings": [[[170, 50], [170, 60], [-170, 60], [-170, 50], [170, 50]]]}}
+		]
+	}`
+	fc := convertFeatureCollection(t, data, Options{SplitAntimeridian: true})
+	for i, expected := range []string{"MultiLineString", "MultiPolygon"} {
+		g := fc.Features[i].Geometry
+		if g.GeoJSONType() != expected {
+			t.Errorf("feature %d: expected %s, got %s", i, expected, g.GeoJSONType())
+		}
+		if b := g.Bound(); b.Min[0] != -180 || b.Max[0] != 180 {
+			t.Errorf("feature %d: expected to touch the antimeridian on both sides, got %v", i, b)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package arcgis2geojson
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// bboxTolerance is how far, in degrees, a computed bbox may poke out of a declared extent or envelope
+// before they are considered to disagree, when coordinates aren't rounded
+const bboxTolerance = 1e-9
+
+// featureEnvelope returns the xmin/ymin/xmax/ymax envelope of a feature, if it has one. an envelope
+// with xmin > xmax crosses the antimeridian.
+func featureEnvelope(f ArcGISFeature) ([]float64, bool) {
+	if f.Xmin == nil || f.Ymin == nil || f.Xmax == nil || f.Ymax == nil {
+		return nil, false
+	}
+	return []float64{*f.Xmin, *f.Ymin, *f.Xmax, *f.Ymax}, true
+}
+
+// bboxBounds returns the bounds covered by a bbox, two of them when it crosses the antimeridian
+func bboxBounds(bbox geojson.BBox) []orb.Bound {
+	if bbox[0] > bbox[2] {
+		return []orb.Bound{
+			{Min: orb.Point{bbox[0], bbox[1]}, Max: orb.Point{180, bbox[3]}},
+			{Min: orb.Point{-180, bbox[1]}, Max: orb.Point{bbox[2], bbox[3]}},
+		}
+	}
+	return []orb.Bound{{Min: orb.Point{bbox[0], bbox[1]}, Max: orb.Point{bbox[2], bbox[3]}}}
+}
+
+// bboxGeometry returns the area covered by a bbox as a geometry
+func bboxGeometry(bbox geojson.BBox) orb.Geometry {
+	bounds := bboxBounds(bbox)
+	if len(bounds) == 1 {
+		return bounds[0]
+	}
+	return orb.Collection{bounds[0], bounds[1]}
+}
+
+// BBoxUnion accumulates the bbox covering several bboxes and geometries. when one of them crosses the
+// antimeridian, or they meet at it from both sides, the union is worked out on the circle of longitudes:
+// it is the smallest range of longitudes covering them all, and crosses the antimeridian itself unless
+// that range doesn't.
+type BBoxUnion struct {
+	bound orb.Bound
+	found bool
+	// the longitude ranges covered, split at the antimeridian
+	ranges [][2]float64
+	// whether a bbox crossed the antimeridian, and whether a range reached it from the east or west
+	crosses, east, west bool
+}
+
+// Add adds a bbox, which crosses the antimeridian when its west is greater than its east
+func (u *BBoxUnion) Add(bbox geojson.BBox) {
+	if len(bbox) != 4 {
+		return
+	}
+	for _, b := range bboxBounds(bbox) {
+		u.addBound(b)
+	}
+	u.crosses = u.crosses || bbox[0] > bbox[2]
+}
+
+// AddGeometry adds the bounds of each part of a geometry, so the parts of a geometry split at the
+// antimeridian give a bbox crossing it
+func (u *BBoxUnion) AddGeometry(g orb.Geometry) {
+	switch t := g.(type) {
+	case nil:
+	case orb.MultiPoint:
+		for _, pt := range t {
+			u.addBound(pt.Bound())
+		}
+	case orb.MultiLineString:
+		for _, ls := range t {
+			u.addBound(ls.Bound())
+		}
+	case orb.MultiPolygon:
+		for _, p := range t {
+			u.addBound(p.Bound())
+		}
+	case orb.Collection:
+		for _, g := range t {
+			u.AddGeometry(g)
+		}
+	default:
+		u.addBound(g.Bound())
+	}
+}
+
+func (u *BBoxUnion) addBound(b orb.Bound) {
+	if u.found {
+		u.bound = u.bound.Union(b)
+	} else {
+		u.bound, u.found = b, true
+	}
+	u.ranges = append(u.ranges, [2]float64{b.Min[0], b.Max[0]})
+	u.east = u.east || b.Max[0] >= 180
+	u.west = u.west || b.Min[0] <= -180
+}
+
+// BBox returns the union, or nil when nothing was added
+func (u *BBoxUnion) BBox() geojson.BBox {
+	if !u.found {
+		return nil
+	}
+	bbox := geojson.NewBBox(u.bound)
+	if !u.crosses && !(u.east && u.west) {
+		return bbox
+	}
+	ranges := append([][2]float64{}, u.ranges...)
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	// the largest gap between the ranges is left out, either between two ranges or around the antimeridian
+	gap, west, east := 0.0, 0.0, 0.0
+	reach := ranges[0][1]
+	for _, r := range ranges[1:] {
+		if r[0]-reach > gap {
+			gap, west, east = r[0]-reach, r[0], reach
+		}
+		reach = math.Max(reach, r[1])
+	}
+	if ranges[0][0]+360-reach >= gap {
+		return bbox
+	}
+	bbox[0], bbox[2] = west, east
+	return bbox
+}
+
+// bboxWithin reports whether a bbox, which may cross the antimeridian, is inside a bound, within tolerance
+func bboxWithin(outer orb.Bound, bbox geojson.BBox, tolerance float64) bool {
+	for _, b := range bboxBounds(bbox) {
+		if !boundContains(outer, b, tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// bboxTolerance returns how far a computed bbox may poke out of a declared extent or envelope before
+// they disagree. rounding coordinates to a precision moves them by up to half its last decimal place.
+func (c *converter) bboxTolerance() float64 {
+	if digits := c.precision(); digits > 0 {
+		return math.Max(bboxTolerance, math.Pow(10, -float64(digits)))
+	}
+	return bboxTolerance
+}
+
+// featureBBox writes the bbox of a feature, preferring its envelope when it agrees with the geometry
+func (c *converter) featureBBox(feature *geojson.Feature, f ArcGISFeature, index int) {
+	envelope, ok := featureEnvelope(f)
+	if ok && c.opts.Clip != nil {
+		// the envelope may have been cut by clipping
+		if feature.Geometry == nil {
+			return
+		}
+		ok = false
+	}
+	if ok && (feature.Geometry == nil || envelope[0] > envelope[2]) {
+		// the geometry is the envelope, or absent
+		feature.BBox = geojson.BBox(envelope)
+		return
+	}
+	if feature.Geometry == nil {
+		return
+	}
+	u := &BBoxUnion{}
+	u.AddGeometry(feature.Geometry)
+	computed := u.BBox()
+	if ok {
+		declared := orb.Bound{Min: orb.Point{envelope[0], envelope[1]}, Max: orb.Point{envelope[2], envelope[3]}}
+		if bboxWithin(declared, computed, c.bboxTolerance()) {
+			computed = geojson.NewBBox(declared)
+		} else {
+			c.warn(index, "envelope %v does not contain the geometry bbox %v", envelope, computed)
+		}
+	}
+	feature.BBox = computed
+}
+
+// collectionBBox writes the bbox of a feature collection, preferring the extent of the response when it
+// agrees with the features. the extent is only used when every input feature was written unchanged, since
+// it describes the whole response. an empty collection gets no bbox.
+func (c *converter) collectionBBox(fc *geojson.FeatureCollection, unchanged bool) {
+	u := &BBoxUnion{}
+	for _, feature := range fc.Features {
+		if len(feature.BBox) == 4 {
+			u.Add(feature.BBox)
+		} else {
+			u.AddGeometry(feature.Geometry)
+		}
+	}
+	computed := u.BBox()
+	if computed == nil {
+		return
+	}
+	if e := c.arcgisJSON.Extent; e != nil && unchanged {
+		declared := orb.Bound{Min: orb.Point{e.Xmin, e.Ymin}, Max: orb.Point{e.Xmax, e.Ymax}}
+		if bboxWithin(declared, computed, c.bboxTolerance()) {
+			computed = geojson.NewBBox(declared)
+		} else {
+			c.warn(-1, "extent %v does not contain the features bbox %v", geojson.NewBBox(declared), computed)
+		}
+	}
+	fc.BBox = computed
+}
+
+// boundContains reports whether inner is inside outer, within tolerance
+func boundContains(outer, inner orb.Bound, tolerance float64) bool {
+	return inner.Min[0] >= outer.Min[0]-tolerance && inner.Min[1] >= outer.Min[1]-tolerance &&
+		inner.Max[0] <= outer.Max[0]+tolerance && inner.Max[1] <= outer.Max[1]+tolerance
+}
@@ -0,0 +1,171 @@
+package arcgis2geojson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+func TestWriteBBox(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1, 2], [3, 1]]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"paths": [[[-1, 5], [2, 6]]]}},
+			{"attributes": {"OBJECTID": 3}, "xmin": 1, "ymin": 1, "xmax": 2, "ymax": 2}
+		]
+	}`
+	fc := convertFeatureCollection(t, data, Options{WriteBBox: true})
+	expected := []geojson.BBox{{1, 1, 3, 2}, {-1, 5, 2, 6}, {1, 1, 2, 2}}
+	for i, f := range fc.Features {
+		if !reflect.DeepEqual(f.BBox, expected[i]) {
+			t.Errorf("feature %d: expected bbox %v, got %v", i, expected[i], f.BBox)
+		}
+	}
+	if !reflect.DeepEqual(fc.BBox, geojson.BBox{-1, 1, 3, 6}) {
+		t.Errorf("expected collection bbox [-1 1 3 6], got %v", fc.BBox)
+	}
+}
+
+func TestWriteBBoxExtent(t *testing.T) {
+	features := `"features": [{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1, 2], [3, 1]]}}]`
+	data := `{"spatialReference": {"wkid": 4326}, "extent": {"xmin": 0, "ymin": 0, "xmax": 10, "ymax": 10}, ` + features + `}`
+	fc := convertFeatureCollection(t, data, Options{WriteBBox: true})
+	if !reflect.DeepEqual(fc.BBox, geojson.BBox{0, 0, 10, 10}) {
+		t.Errorf("expected the extent as collection bbox, got %v", fc.BBox)
+	}
+
+	data = `{"spatialReference": {"wkid": 4326}, "extent": {"xmin": 0, "ymin": 0, "xmax": 2, "ymax": 2}, ` + features + `}`
+	b, report, err := ConvertWithReport([]byte(data), Options{WriteBBox: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, err = geojson.UnmarshalFeatureCollection(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fc.BBox, geojson.BBox{1, 1, 3, 2}) {
+		t.Errorf("expected the computed collection bbox, got %v", fc.BBox)
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0].Feature != -1 {
+		t.Errorf("expected an extent warning, got %v", report.Warnings)
+	}
+
+	// the extent no longer describes filtered or clipped features
+	data = `{"spatialReference": {"wkid": 4326}, "extent": {"xmin": 0, "ymin": 0, "xmax": 10, "ymax": 10}, "features": [
+		{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1, 2], [3, 1]]}},
+		{"attributes": {"OBJECTID": 2}, "geometry": {"points": [[9, 9]]}}
+	]}`
+	for name, opts := range map[string]Options{
+		"where": {WriteBBox: true, Where: "OBJECTID = 1"},
+		"clip":  {WriteBBox: true, Clip: orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{5, 5}}},
+	} {
+		fc = convertFeatureCollection(t, data, opts)
+		if !reflect.DeepEqual(fc.BBox, geojson.BBox{1, 1, 3, 2}) {
+			t.Errorf("%s: expected the computed collection bbox, got %v", name, fc.BBox)
+		}
+	}
+
+	// an empty collection has no bbox
+	data = `{"spatialReference": {"wkid": 4326}, "extent": {"xmin": 0, "ymin": 0, "xmax": 10, "ymax": 10}, "features": []}`
+	fc = convertFeatureCollection(t, data, Options{WriteBBox: true})
+	if fc.BBox != nil {
+		t.Errorf("expected no bbox on an empty collection, got %v", fc.BBox)
+	}
+}
+
+func TestBBoxUnion(t *testing.T) {
+	tests := []struct {
+		name     string
+		bboxes   []geojson.BBox
+		expected geojson.BBox
+	}{
+		{"plain", []geojson.BBox{{0, 0, 1, 1}, {5, -1, 6, 0}}, geojson.BBox{0, -1, 6, 1}},
+		{"no crossing near the antimeridian", []geojson.BBox{{-179, 0, -178, 1}, {178, 0, 179, 1}}, geojson.BBox{-179, 0, 179, 1}},
+		{"crossing", []geojson.BBox{{170, 0, -170, 1}, {175, 2, 176, 3}}, geojson.BBox{170, 0, -170, 3}},
+		{"crossing and either side", []geojson.BBox{{178, 0, -178, 1}, {-10, 0, 10, 1}, {160, 0, 170, 1}}, geojson.BBox{-10, 0, -178, 1}},
+		// equal gaps either side of the wide box, so the first one is left out
+		{"crossing and wide", []geojson.BBox{{179, 0, -179, 1}, {-100, 0, 100, 1}}, geojson.BBox{-100, 0, -179, 1}},
+		{"meeting at the antimeridian", []geojson.BBox{{170, 0, 180, 1}, {-180, 0, -170, 1}}, geojson.BBox{170, 0, -170, 1}},
+	}
+	for _, test := range tests {
+		u := &BBoxUnion{}
+		for _, b := range test.bboxes {
+			u.Add(b)
+		}
+		if bbox := u.BBox(); !reflect.DeepEqual(bbox, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, bbox)
+		}
+	}
+	if bbox := (&BBoxUnion{}).BBox(); bbox != nil {
+		t.Errorf("expected no bbox of nothing, got %v", bbox)
+	}
+}
+
+func TestWriteBBoxAntimeridian(t *testing.T) {
+	// a line crossing the antimeridian, split into two parts
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"paths": [[[170, 0], [-170, 2]]]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"points": [[175, 5]]}}
+		]
+	}`
+	fc := convertFeatureCollection(t, data, Options{WriteBBox: true, SplitAntimeridian: true})
+	if !reflect.DeepEqual(fc.Features[0].BBox, geojson.BBox{170, 0, -170, 2}) {
+		t.Errorf("expected a feature bbox crossing the antimeridian, got %v", fc.Features[0].BBox)
+	}
+	if !reflect.DeepEqual(fc.BBox, geojson.BBox{170, 0, -170, 5}) {
+		t.Errorf("expected a collection bbox crossing the antimeridian, got %v", fc.BBox)
+	}
+}
+
+func TestWriteBBoxPrecision(t *testing.T) {
+	// rounding to 2 places moves the point just outside the extent
+	data := `{"spatialReference": {"wkid": 4326}, "extent": {"xmin": 0, "ymin": 0, "xmax": 1.004, "ymax": 1},
+		"features": [{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1.004, 1]]}}]}`
+	_, report, err := ConvertWithReport([]byte(data), Options{WriteBBox: true, Precision: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("expected no extent warning after rounding, got %v", report.Warnings)
+	}
+}
+
+func TestEnvelopes(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "xmin": 0, "ymin": -1, "xmax": 2, "ymax": 0},
+			{"attributes": {"OBJECTID": 2}, "xmin": 170, "ymin": 50, "xmax": -170, "ymax": 60}
+		]
+	}`
+	fc := convertFeatureCollection(t, data, Options{})
+	if p, ok := fc.Features[0].Geometry.(orb.Polygon); !ok || p.Bound() != (orb.Bound{Min: orb.Point{0, -1}, Max: orb.Point{2, 0}}) {
+		t.Errorf("expected an envelope polygon touching the equator and prime meridian, got %v", fc.Features[0].Geometry)
+	}
+	if mp, ok := fc.Features[1].Geometry.(orb.MultiPolygon); !ok || len(mp) != 2 {
+		t.Errorf("expected an envelope split at the antimeridian, got %v", fc.Features[1].Geometry)
+	}
+
+	raw := convertRawFeatureCollection(t, data, Options{EnvelopesAsBBox: true, WriteBBox: true})
+	expected := [][]float64{{0, -1, 2, 0}, {170, 50, -170, 60}}
+	for i, f := range raw.Features {
+		if string(f.Geometry) != "null" || !reflect.DeepEqual(f.BBox, expected[i]) {
+			t.Errorf("feature %d: expected null geometry with bbox %v, got %s with bbox %v", i, expected[i], f.Geometry, f.BBox)
+		}
+	}
+	// from 0 east across the antimeridian to -170 rather than every longitude
+	if !reflect.DeepEqual(raw.BBox, []float64{0, -1, -170, 60}) {
+		t.Errorf("expected collection bbox [0 -1 -170 60], got %v", raw.BBox)
+	}
+
+	bbox := orb.Bound{Min: orb.Point{175, 55}, Max: orb.Point{176, 56}}
+	raw = convertRawFeatureCollection(t, data, Options{EnvelopesAsBBox: true, BBox: &bbox})
+	if len(raw.Features) != 1 || raw.Features[0].ID != 2.0 {
+		t.Errorf("expected the spatial filter to match the antimeridian envelope, got %v", raw.Features)
+	}
+}
@@ -0,0 +1,312 @@
+// Package client fetches features from the query endpoint of an arcgis rest feature or map service
+// layer and converts them to geojson
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+// Client queries one arcgis rest layer
+type Client struct {
+	// URL is the url of the layer, e.g. https://host/arcgis/rest/services/Parcels/FeatureServer/0
+	URL string
+	// HTTPClient makes the requests, it defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// New returns a client for the layer at url
+func New(url string) *Client {
+	return &Client{URL: url}
+}
+
+// Query selects the features of a layer
+type Query struct {
+	// Where is an sql where clause over the layer's attributes, it defaults to 1=1
+	Where string
+	// OutFields are the attributes returned, they default to every attribute
+	OutFields []string
+	// Geometry keeps only features intersecting the area of a bound, polygon or multipolygon, in wgs84
+	Geometry orb.Geometry
+	// OutSR is the wkid of the spatial reference features are returned in, it defaults to 4326, the
+	// only spatial reference the converter accepts
+	OutSR int
+	// OrderByFields orders the features, e.g. "OBJECTID ASC". some servers need an order to page
+	// through results reliably.
+	OrderByFields string
+	// PageSize is the number of features asked for in each request, the server's maxRecordCount when 0.
+	// with StrategyExtent it is the number of features that makes a tile split.
+	PageSize int
+
+	// Strategy is how features are fetched a page at a time
+	Strategy Strategy
+	// Concurrency is the number of batches fetched at once with StrategyObjectIDs, it defaults to
+	// DefaultConcurrency
+	Concurrency int
+}
+
+// Strategy is a way of fetching the features of a layer a page at a time
+type Strategy int
+
+const (
+	// StrategyOffset pages through features with resultOffset and resultRecordCount, which needs
+	// arcgis server 10.3 or later
+	StrategyOffset Strategy = iota
+	// StrategyObjectIDs fetches the object ids of the features first, then the features in batches of
+	// object ids, in object id order
+	StrategyObjectIDs
+	// StrategyAuto uses StrategyOffset when the layer supports pagination, StrategyObjectIDs otherwise,
+	// and StrategyExtent when the layer can't return object ids either
+	StrategyAuto
+	// StrategyExtent splits the queried extent into quadrants until no tile holds more features than
+	// the layer returns at once, for layers supporting neither pagination nor returnIdsOnly
+	StrategyExtent
+)
+
+// ParseStrategy parses a fetch strategy name (offset, objectids, extent or auto)
+func ParseStrategy(s string) (Strategy, error) {
+	switch strings.ToLower(s) {
+	case "", "offset":
+		return StrategyOffset, nil
+	case "objectids", "ids":
+		return StrategyObjectIDs, nil
+	case "extent":
+		return StrategyExtent, nil
+	case "auto":
+		return StrategyAuto, nil
+	}
+	return StrategyOffset, fmt.Errorf("error: unknown fetch strategy %q", s)
+}
+
+// ServerError is an error returned by the arcgis server in the body of a response
+type ServerError struct {
+	Code    int      `json:"code"`
+	Message string   `json:"message"`
+	Details []string `json:"details"`
+}
+
+func (e *ServerError) Error() string {
+	s := fmt.Sprintf("error: arcgis server error %d: %s", e.Code, e.Message)
+	if len(e.Details) != 0 {
+		s += " (" + strings.Join(e.Details, "; ") + ")"
+	}
+	return s
+}
+
+// page is the part of a query response needed to page through results
+type page struct {
+	ExceededTransferLimit bool              `json:"exceededTransferLimit"`
+	Features              []json.RawMessage `json:"features"`
+}
+
+// Pages fetches the features matching q with the query's strategy, calling fn with the arcgis json of
+// each page. it stops at the first error, from the server or fn.
+func (c *Client) Pages(ctx context.Context, q Query, fn func(data []byte) error) error {
+	strategy := q.Strategy
+	if strategy == StrategyAuto {
+		info, err := c.Info(ctx)
+		if err != nil {
+			return err
+		}
+		if info.AdvancedQueryCapabilities.SupportsPagination {
+			return c.offsetPages(ctx, q, fn)
+		}
+		err = c.objectIDPages(ctx, q, info, fn)
+		if ie := (*objectIDsError)(nil); errors.As(err, &ie) {
+			return c.extentPages(ctx, q, info, fn)
+		}
+		return err
+	}
+	switch strategy {
+	case StrategyObjectIDs:
+		return c.objectIDPages(ctx, q, nil, fn)
+	case StrategyExtent:
+		return c.extentPages(ctx, q, nil, fn)
+	}
+	return c.offsetPages(ctx, q, fn)
+}
+
+// offsetPages fetches the features matching q a page at a time with resultOffset and
+// resultRecordCount, until the server no longer reports exceededTransferLimit
+func (c *Client) offsetPages(ctx context.Context, q Query, fn func(data []byte) error) error {
+	params, err := q.values()
+	if err != nil {
+		return err
+	}
+	offset := 0
+	for {
+		if q.PageSize > 0 {
+			params.Set("resultRecordCount", strconv.Itoa(q.PageSize))
+		}
+		params.Set("resultOffset", strconv.Itoa(offset))
+		p := &page{}
+		data, err := c.request(ctx, "query", params, p)
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+		// servers may return fewer features than asked for, so continue from what was returned
+		if !p.ExceededTransferLimit || len(p.Features) == 0 {
+			return nil
+		}
+		offset += len(p.Features)
+	}
+}
+
+// LayerInfo is the part of a layer's description used to query it
+type LayerInfo struct {
+	Name                      string `json:"name"`
+	MaxRecordCount            int    `json:"maxRecordCount"`
+	AdvancedQueryCapabilities struct {
+		SupportsPagination bool `json:"supportsPagination"`
+	} `json:"advancedQueryCapabilities"`
+	// Extent is the arcgis json extent of the layer's features, in the layer's spatial reference. it is
+	// kept raw since empty layers may have "NaN" coordinates.
+	Extent json.RawMessage `json:"extent"`
+}
+
+// Info fetches the description of the layer
+func (c *Client) Info(ctx context.Context) (*LayerInfo, error) {
+	info := &LayerInfo{}
+	if _, err := c.request(ctx, "", url.Values{"f": {"json"}}, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// request posts params to the layer's url, or the endpoint below it, and decodes the response body
+// into v, returning the body
+func (c *Client) request(ctx context.Context, endpoint string, params url.Values, v interface{}) ([]byte, error) {
+	u := strings.TrimSuffix(c.URL, "/")
+	if endpoint != "" {
+		u += "/" + endpoint
+	}
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error: request %s: %s", u, resp.Status)
+	}
+	// arcgis servers report errors in the body of a 200 response
+	failure := struct {
+		Error *ServerError `json:"error"`
+	}{}
+	if err := json.Unmarshal(data, &failure); err != nil {
+		return nil, fmt.Errorf("error: request %s: %v", u, err)
+	}
+	if failure.Error != nil {
+		return nil, failure.Error
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("error: request %s: %v", u, err)
+	}
+	return data, nil
+}
+
+// values returns the request parameters of a query, without the paging parameters
+func (q Query) values() (url.Values, error) {
+	params := url.Values{}
+	params.Set("f", "json")
+	params.Set("returnGeometry", "true")
+	where := q.Where
+	if where == "" {
+		where = "1=1"
+	}
+	params.Set("where", where)
+	outFields := "*"
+	if len(q.OutFields) != 0 {
+		outFields = strings.Join(q.OutFields, ",")
+	}
+	params.Set("outFields", outFields)
+	outSR := q.OutSR
+	if outSR == 0 {
+		outSR = 4326
+	}
+	params.Set("outSR", strconv.Itoa(outSR))
+	if q.OrderByFields != "" {
+		params.Set("orderByFields", q.OrderByFields)
+	}
+	if q.Geometry != nil {
+		geometry, geometryType, err := esriGeometry(q.Geometry)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("geometry", geometry)
+		params.Set("geometryType", geometryType)
+		params.Set("inSR", "4326")
+		params.Set("spatialRel", "esriSpatialRelIntersects")
+	}
+	return params, nil
+}
+
+// esriGeometry encodes a geometry filter as arcgis json, returning it with its arcgis geometry type
+func esriGeometry(g orb.Geometry) (string, string, error) {
+	var rings [][][]float64
+	switch t := g.(type) {
+	case orb.Bound:
+		return fmt.Sprintf("%v,%v,%v,%v", t.Min[0], t.Min[1], t.Max[0], t.Max[1]), "esriGeometryEnvelope", nil
+	case orb.Polygon:
+		rings = polygonRings(t)
+	case orb.MultiPolygon:
+		for _, p := range t {
+			rings = append(rings, polygonRings(p)...)
+		}
+	default:
+		return "", "", fmt.Errorf("error: a query geometry must be a bound, polygon or multipolygon, got a %s", g.GeoJSONType())
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"rings":            rings,
+		"spatialReference": map[string]int{"wkid": 4326},
+	})
+	return string(b), "esriGeometryPolygon", err
+}
+
+// polygonRings returns the rings of a polygon wound the arcgis way: outer rings clockwise, holes
+// counterclockwise
+func polygonRings(p orb.Polygon) [][][]float64 {
+	rings := [][][]float64{}
+	for i, r := range p {
+		want := orb.CCW
+		if i == 0 {
+			want = orb.CW
+		}
+		ring := make([][]float64, len(r))
+		for j, pt := range r {
+			ring[j] = []float64{pt[0], pt[1]}
+		}
+		if r.Orientation() != want {
+			for a, b := 0, len(ring)-1; a < b; a, b = a+1, b-1 {
+				ring[a], ring[b] = ring[b], ring[a]
+			}
+		}
+		rings = append(rings, ring)
+	}
+	return rings
+}
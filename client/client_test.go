@@ -0,0 +1,648 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/engelsjk/arcgis2geojson"
+	"github.com/paulmach/orb"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// fakeLayer stands in for a layer of point features with object ids 1 to features, returning at most
+// maxRecordCount features a request
+type fakeLayer struct {
+	features       int
+	maxRecordCount int
+	// noPagination makes the layer ignore resultOffset, like servers before 10.3
+	noPagination bool
+	// noObjectIDs makes the layer fail returnIdsOnly queries and leave out object ids
+	noObjectIDs bool
+	// ignoreIDsOnly makes the layer answer returnIdsOnly queries with features, like a normal query
+	ignoreIDsOnly bool
+	// duplicateIDs makes the object ids of every page of an offset query start from 1, so pages share ids
+	duplicateIDs bool
+	// failFrom makes queries for features from this offset on fail, when it isn't 0
+	failFrom int
+
+	mu sync.Mutex
+	// requests are the parameters of each query request
+	requests []map[string]string
+	// infoRequests counts the requests for the layer's description
+	infoRequests int
+	// inFlight and maxInFlight count the queries being answered at once
+	inFlight    int
+	maxInFlight int
+}
+
+func (l *fakeLayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Path {
+	case "/FeatureServer/0":
+		l.mu.Lock()
+		l.infoRequests++
+		l.mu.Unlock()
+		fmt.Fprintf(w, `{"name": "points", "maxRecordCount": %d, "advancedQueryCapabilities": {"supportsPagination": %v}, "extent": {"xmin": 0, "ymin": 0, "xmax": %d, "ymax": 2, "spatialReference": {"wkid": 4326}}}`, l.maxRecordCount, !l.noPagination, l.features+1)
+		return
+	case "/FeatureServer/0/query":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	params := map[string]string{}
+	for k := range r.PostForm {
+		params[k] = r.PostForm.Get(k)
+	}
+	l.mu.Lock()
+	l.requests = append(l.requests, params)
+	l.inFlight++
+	if l.inFlight > l.maxInFlight {
+		l.maxInFlight = l.inFlight
+	}
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.inFlight--
+		l.mu.Unlock()
+	}()
+	// give concurrent requests time to overlap
+	time.Sleep(time.Millisecond)
+
+	if params["where"] == "bad" {
+		fmt.Fprint(w, `{"error": {"code": 400, "message": "Unable to complete operation.", "details": ["Invalid where clause"]}}`)
+		return
+	}
+	if params["returnIdsOnly"] == "true" && l.noObjectIDs {
+		fmt.Fprint(w, `{"error": {"code": 400, "message": "Unable to complete operation.", "details": ["returnIdsOnly is not supported"]}}`)
+		return
+	}
+	if params["returnIdsOnly"] == "true" && !l.ignoreIDsOnly {
+		// ids in no particular order
+		ids := []string{}
+		for id := l.features; id >= 1; id-- {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		fmt.Fprintf(w, `{"objectIdFieldName": "OBJECTID", "objectIds": [%s]}`, strings.Join(ids, ","))
+		return
+	}
+	if s := params["objectIds"]; s != "" {
+		ids := strings.Split(s, ",")
+		features := []string{}
+		// features in reverse order
+		for i := len(ids) - 1; i >= 0 && len(features) < l.maxRecordCount; i-- {
+			features = append(features, fmt.Sprintf(`{"attributes": {"OBJECTID": %s}, "geometry": {"points": [[%s, 1]]}}`, ids[i], ids[i]))
+		}
+		fmt.Fprintf(w, `{"objectIdFieldName": "OBJECTID", "spatialReference": {"wkid": 4326}, "exceededTransferLimit": %v, "features": [%s]}`, len(ids) > l.maxRecordCount, strings.Join(features, ","))
+		return
+	}
+	// feature id is at id, 1
+	ids := []int{}
+	var polygon orb.Polygon
+	if params["geometryType"] == "esriGeometryPolygon" {
+		geometry := struct {
+			Rings [][]orb.Point `json:"rings"`
+		}{}
+		if err := json.Unmarshal([]byte(params["geometry"]), &geometry); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, r := range geometry.Rings {
+			polygon = append(polygon, orb.Ring(r))
+		}
+	}
+	for id := 1; id <= l.features; id++ {
+		if params["geometryType"] == "esriGeometryEnvelope" {
+			bbox := []float64{}
+			for _, v := range strings.Split(params["geometry"], ",") {
+				f, _ := strconv.ParseFloat(v, 64)
+				bbox = append(bbox, f)
+			}
+			if float64(id) < bbox[0] || float64(id) > bbox[2] || 1 < bbox[1] || 1 > bbox[3] {
+				continue
+			}
+		}
+		if polygon != nil && !arcgis2geojson.Intersects(orb.Point{float64(id), 1}, polygon) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	offset, _ := strconv.Atoi(params["resultOffset"])
+	if l.noPagination {
+		offset = 0
+	}
+	if l.failFrom != 0 && offset >= l.failFrom {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	count, _ := strconv.Atoi(params["resultRecordCount"])
+	if count == 0 || count > l.maxRecordCount {
+		count = l.maxRecordCount
+	}
+	features := []string{}
+	for i := offset; i < len(ids) && len(features) < count; i++ {
+		id := ids[i]
+		objectID := id
+		if l.duplicateIDs {
+			objectID = len(features) + 1
+		}
+		if l.noObjectIDs {
+			features = append(features, fmt.Sprintf(`{"attributes": {"NAME": "p%d"}, "geometry": {"points": [[%d, 1]]}}`, id, id))
+		} else {
+			features = append(features, fmt.Sprintf(`{"attributes": {"OBJECTID": %d}, "geometry": {"x": %d, "y": 1, "points": [[%d, 1]]}}`, objectID, id, id))
+		}
+	}
+	exceeded := offset+len(features) < len(ids)
+	idField := `"objectIdFieldName": "OBJECTID", `
+	if l.noObjectIDs {
+		idField = ""
+	}
+	fmt.Fprintf(w, `{%s"spatialReference": {"wkid": 4326}, "exceededTransferLimit": %v, "features": [%s]}`, idField, exceeded, strings.Join(features, ","))
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		features       int
+		maxRecordCount int
+		pageSize       int
+		requests       int
+	}{
+		{0, 10, 0, 1},
+		{25, 10, 0, 3},
+		{30, 10, 0, 3},
+		{25, 10, 4, 7},
+		// the server returns fewer features than asked for
+		{25, 10, 20, 3},
+	}
+	for _, test := range tests {
+		layer := &fakeLayer{features: test.features, maxRecordCount: test.maxRecordCount}
+		server := httptest.NewServer(layer)
+		c := New(server.URL + "/FeatureServer/0")
+		buf := &bytes.Buffer{}
+		report, err := c.Convert(context.Background(), Query{PageSize: test.pageSize}, arcgis2geojson.Options{IDAttribute: "OBJECTID"}, buf)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%d features: %v: %s", test.features, err, buf)
+		}
+		if len(fc.Features) != test.features {
+			t.Errorf("%d features: got %d features", test.features, len(fc.Features))
+		}
+		for i, f := range fc.Features {
+			if f.ID != float64(i+1) {
+				t.Errorf("%d features: expected feature %d to have id %d, got %v", test.features, i, i+1, f.ID)
+				break
+			}
+		}
+		if len(layer.requests) != test.requests {
+			t.Errorf("%d features: expected %d requests, got %d", test.features, test.requests, len(layer.requests))
+		}
+		if report.Stats.InputFeatures != test.features || report.Stats.OutputFeatures != test.features {
+			t.Errorf("%d features: expected stats for every feature, got %+v", test.features, report.Stats)
+		}
+	}
+}
+
+func TestConvertDuplicateIDs(t *testing.T) {
+	// the second page repeats the object ids 1 to 5 of the first
+	tests := []struct {
+		policy arcgis2geojson.DuplicateIDPolicy
+		// ids and x coordinates of the features written
+		ids []float64
+		xs  []float64
+	}{
+		{arcgis2geojson.DuplicateIDsKeep, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 1, 2, 3, 4, 5}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}},
+		{arcgis2geojson.DuplicateIDsKeepFirst, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{arcgis2geojson.DuplicateIDsKeepLast, []float64{6, 7, 8, 9, 10, 1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10, 11, 12, 13, 14, 15}},
+		{arcgis2geojson.DuplicateIDsRewrite, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}},
+	}
+	for _, test := range tests {
+		layer := &fakeLayer{features: 15, maxRecordCount: 10, duplicateIDs: true}
+		server := httptest.NewServer(layer)
+		buf := &bytes.Buffer{}
+		opts := arcgis2geojson.Options{DuplicateIDs: test.policy, WriteBBox: true}
+		report, err := New(server.URL+"/FeatureServer/0").Convert(context.Background(), Query{}, opts, buf)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+		if err != nil {
+			t.Fatalf("policy %d: %v: %s", test.policy, err, buf)
+		}
+		ids, xs := []float64{}, []float64{}
+		for _, f := range fc.Features {
+			id, _ := f.ID.(float64)
+			ids = append(ids, id)
+			xs = append(xs, f.Geometry.(orb.Point)[0])
+		}
+		if !reflect.DeepEqual(ids, test.ids) || !reflect.DeepEqual(xs, test.xs) {
+			t.Errorf("policy %d: expected ids %v at %v, got %v at %v", test.policy, test.ids, test.xs, ids, xs)
+		}
+		if len(report.DuplicateIDs) != 5 || !reflect.DeepEqual(report.DuplicateIDs[0].Features, []int{0, 10}) {
+			t.Errorf("policy %d: expected 5 duplicate ids shared across pages, got %v", test.policy, report.DuplicateIDs)
+		}
+		if report.Stats.OutputFeatures != len(test.ids) {
+			t.Errorf("policy %d: expected %d output features, got %+v", test.policy, len(test.ids), report.Stats)
+		}
+		xmin, xmax := math.Inf(1), math.Inf(-1)
+		for _, x := range test.xs {
+			xmin, xmax = math.Min(xmin, x), math.Max(xmax, x)
+		}
+		if !reflect.DeepEqual(fc.BBox, geojson.BBox{xmin, 1, xmax, 1}) {
+			t.Errorf("policy %d: expected the collection bbox of the features written, got %v", test.policy, fc.BBox)
+		}
+	}
+
+	layer := &fakeLayer{features: 15, maxRecordCount: 10, duplicateIDs: true}
+	server := httptest.NewServer(layer)
+	defer server.Close()
+	opts := arcgis2geojson.Options{DuplicateIDs: arcgis2geojson.DuplicateIDsError}
+	buf := &bytes.Buffer{}
+	_, err := New(server.URL+"/FeatureServer/0").Convert(context.Background(), Query{}, opts, buf)
+	var de *arcgis2geojson.DuplicateIDError
+	if !errors.As(err, &de) || !reflect.DeepEqual(de.Features, []int{0, 10}) {
+		t.Errorf("expected a duplicate id error for features 0 and 10, got %v", err)
+	}
+	if fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes()); err != nil || len(fc.Features) != 10 {
+		t.Errorf("expected the 10 features written before the error in a closed collection, got %v: %s", err, buf)
+	}
+}
+
+func TestConvertLargeIDs(t *testing.T) {
+	// object ids above 2^53 that a float64 can't tell apart
+	page := `{"objectIdFieldName": "OBJECTID", "spatialReference": {"wkid": 4326}, "features": [
+		{"attributes": {"OBJECTID": 9007199254740992}, "geometry": {"x": 1, "y": 1}},
+		{"attributes": {"OBJECTID": 9007199254740993}, "geometry": {"x": 2, "y": 1}}
+	]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/query") {
+			fmt.Fprint(w, page)
+			return
+		}
+		fmt.Fprint(w, `{"name": "points", "maxRecordCount": 10}`)
+	}))
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	opts := arcgis2geojson.Options{DuplicateIDs: arcgis2geojson.DuplicateIDsError}
+	report, err := New(server.URL+"/FeatureServer/0").Convert(context.Background(), Query{}, opts, buf)
+	if err != nil || len(report.DuplicateIDs) != 0 {
+		t.Fatalf("expected no duplicate ids, got %v, %v", err, report.DuplicateIDs)
+	}
+	if !strings.Contains(buf.String(), `"id":9007199254740992`) || !strings.Contains(buf.String(), `"id":9007199254740993`) {
+		t.Errorf("expected both ids to be written, got %s", buf)
+	}
+}
+
+func TestConvertBBoxAntimeridian(t *testing.T) {
+	// a line split at the antimeridian and a point near it, whose collection bbox crosses the antimeridian
+	// instead of spanning the world
+	page := `{"objectIdFieldName": "OBJECTID", "spatialReference": {"wkid": 4326}, "features": [
+		{"attributes": {"OBJECTID": 1}, "geometry": {"paths": [[[170, 1], [-170, 2]]]}},
+		{"attributes": {"OBJECTID": 2}, "geometry": {"points": [[175, 3]]}}
+	]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/query") {
+			fmt.Fprint(w, page)
+			return
+		}
+		fmt.Fprint(w, `{"name": "points", "maxRecordCount": 10}`)
+	}))
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	opts := arcgis2geojson.Options{WriteBBox: true, SplitAntimeridian: true}
+	if _, err := New(server.URL+"/FeatureServer/0").Convert(context.Background(), Query{}, opts, buf); err != nil {
+		t.Fatal(err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fc.BBox, geojson.BBox{170, 1, -170, 3}) {
+		t.Errorf("expected a collection bbox crossing the antimeridian, got %v", fc.BBox)
+	}
+}
+
+func TestConvertPageError(t *testing.T) {
+	layer := &fakeLayer{features: 25, maxRecordCount: 10, failFrom: 10}
+	server := httptest.NewServer(layer)
+	defer server.Close()
+	buf := &bytes.Buffer{}
+	report, err := New(server.URL+"/FeatureServer/0").Convert(context.Background(), Query{}, arcgis2geojson.Options{}, buf)
+	if err == nil {
+		t.Fatal("expected the failed page to fail the conversion")
+	}
+	fc, jsonErr := geojson.UnmarshalFeatureCollection(buf.Bytes())
+	if jsonErr != nil || len(fc.Features) != 10 {
+		t.Errorf("expected the first page in a closed collection, got %v: %s", jsonErr, buf)
+	}
+	if report == nil || report.Stats.InputFeatures != 10 {
+		t.Errorf("expected a report of the first page, got %+v", report)
+	}
+}
+
+func TestConvertReport(t *testing.T) {
+	layer := &fakeLayer{features: 25, maxRecordCount: 10}
+	server := httptest.NewServer(layer)
+	defer server.Close()
+	report, err := New(server.URL+"/FeatureServer/0").Convert(context.Background(), Query{}, arcgis2geojson.Options{}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every feature has an unsupported x and y in its geometry
+	if len(report.Warnings) != 25 || report.Warnings[24].Feature != 24 {
+		t.Errorf("expected a warning for each of 25 features, got %v", report.Warnings)
+	}
+	if expected := (geojson.BBox{1, 1, 25, 1}); fmt.Sprint(report.Stats.Extent) != fmt.Sprint(expected) {
+		t.Errorf("expected extent %v, got %v", expected, report.Stats.Extent)
+	}
+}
+
+func TestQueryParameters(t *testing.T) {
+	layer := &fakeLayer{features: 1, maxRecordCount: 10}
+	server := httptest.NewServer(layer)
+	defer server.Close()
+	c := New(server.URL + "/FeatureServer/0/")
+	q := Query{
+		Where:         "CTYNAME = 'RENTON'",
+		OutFields:     []string{"OBJECTID", "CTYNAME"},
+		Geometry:      orb.Bound{Min: orb.Point{-122.5, 47}, Max: orb.Point{-122, 47.5}},
+		OrderByFields: "OBJECTID ASC",
+		PageSize:      5,
+	}
+	if err := c.Pages(context.Background(), q, func([]byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"f":                 "json",
+		"returnGeometry":    "true",
+		"where":             "CTYNAME = 'RENTON'",
+		"outFields":         "OBJECTID,CTYNAME",
+		"outSR":             "4326",
+		"orderByFields":     "OBJECTID ASC",
+		"geometry":          "-122.5,47,-122,47.5",
+		"geometryType":      "esriGeometryEnvelope",
+		"inSR":              "4326",
+		"spatialRel":        "esriSpatialRelIntersects",
+		"resultOffset":      "0",
+		"resultRecordCount": "5",
+	}
+	for k, v := range expected {
+		if layer.requests[0][k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, layer.requests[0][k])
+		}
+	}
+
+	// polygons are sent as arcgis json rings, outer rings clockwise
+	q = Query{Geometry: orb.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}}
+	if err := c.Pages(context.Background(), q, func([]byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	geometry := struct {
+		Rings [][][]float64 `json:"rings"`
+	}{}
+	if err := json.Unmarshal([]byte(layer.requests[1]["geometry"]), &geometry); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(geometry.Rings) != "[[[0 0] [0 1] [1 1] [1 0] [0 0]]]" || layer.requests[1]["geometryType"] != "esriGeometryPolygon" {
+		t.Errorf("unexpected polygon filter %v %s", geometry.Rings, layer.requests[1]["geometryType"])
+	}
+
+	if err := c.Pages(context.Background(), Query{Geometry: orb.Point{0, 0}}, func([]byte) error { return nil }); err == nil {
+		t.Error("expected an error for a point filter")
+	}
+}
+
+func TestServerError(t *testing.T) {
+	server := httptest.NewServer(&fakeLayer{features: 1, maxRecordCount: 10})
+	defer server.Close()
+
+	_, err := New(server.URL+"/FeatureServer/0").Convert(context.Background(), Query{Where: "bad"}, arcgis2geojson.Options{}, &bytes.Buffer{})
+	se, ok := err.(*ServerError)
+	if !ok || se.Code != 400 {
+		t.Fatalf("expected a server error, got %v", err)
+	}
+	if expected := "error: arcgis server error 400: Unable to complete operation. (Invalid where clause)"; se.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, se.Error())
+	}
+
+	_, err = New(server.URL+"/MapServer/0").Convert(context.Background(), Query{}, arcgis2geojson.Options{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestConvertObjectIDs(t *testing.T) {
+	tests := []struct {
+		features    int
+		pageSize    int
+		concurrency int
+		strategy    Strategy
+		requests    int
+	}{
+		{0, 0, 0, StrategyObjectIDs, 1},
+		// ids, then 3 batches of the layer's maxRecordCount
+		{25, 0, 0, StrategyObjectIDs, 4},
+		{25, 5, 2, StrategyObjectIDs, 6},
+		{25, 1, 3, StrategyObjectIDs, 26},
+		// the layer doesn't support pagination
+		{25, 10, 0, StrategyAuto, 4},
+	}
+	for _, test := range tests {
+		layer := &fakeLayer{features: test.features, maxRecordCount: 10, noPagination: true}
+		server := httptest.NewServer(layer)
+		c := New(server.URL + "/FeatureServer/0")
+		buf := &bytes.Buffer{}
+		q := Query{PageSize: test.pageSize, Concurrency: test.concurrency, Strategy: test.strategy}
+		_, err := c.Convert(context.Background(), q, arcgis2geojson.Options{IDAttribute: "OBJECTID"}, buf)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%+v: %v: %s", test, err, buf)
+		}
+		if len(fc.Features) != test.features {
+			t.Errorf("%+v: got %d features", test, len(fc.Features))
+		}
+		for i, f := range fc.Features {
+			if f.ID != float64(i+1) {
+				t.Errorf("%+v: expected feature %d to have id %d, got %v", test, i, i+1, f.ID)
+				break
+			}
+		}
+		if len(layer.requests) != test.requests {
+			t.Errorf("%+v: expected %d queries, got %d", test, test.requests, len(layer.requests))
+		}
+		concurrency := test.concurrency
+		if concurrency == 0 {
+			concurrency = DefaultConcurrency
+		}
+		if layer.maxInFlight > concurrency {
+			t.Errorf("%+v: expected at most %d queries at once, got %d", test, concurrency, layer.maxInFlight)
+		}
+	}
+
+	// batches larger than the server returns
+	layer := &fakeLayer{features: 25, maxRecordCount: 10}
+	server := httptest.NewServer(layer)
+	defer server.Close()
+	q := Query{PageSize: 20, Strategy: StrategyObjectIDs}
+	err := New(server.URL+"/FeatureServer/0").Pages(context.Background(), q, func([]byte) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "transfer limit") {
+		t.Errorf("expected a transfer limit error, got %v", err)
+	}
+}
+
+func TestConvertExtent(t *testing.T) {
+	tests := []struct {
+		features    int
+		noObjectIDs bool
+		strategy    Strategy
+		geometry    orb.Geometry
+		expected    int
+	}{
+		{0, false, StrategyExtent, nil, 0},
+		{25, false, StrategyExtent, nil, 25},
+		// features are told apart by a hash of their geometry and attributes
+		{25, true, StrategyExtent, nil, 25},
+		// the layer supports neither pagination nor returnIdsOnly
+		{40, true, StrategyAuto, nil, 40},
+		{25, false, StrategyExtent, orb.Bound{Min: orb.Point{5, 0}, Max: orb.Point{20, 2}}, 16},
+		// a triangle holding the features at x 10 to 20
+		{25, false, StrategyExtent, orb.Polygon{{{4.5, 0}, {20.5, 0}, {20.5, 3}, {4.5, 0}}}, 11},
+		{25, true, StrategyExtent, orb.MultiPolygon{{{{4.5, 0}, {20.5, 0}, {20.5, 3}, {4.5, 0}}}}, 11},
+	}
+	for _, test := range tests {
+		layer := &fakeLayer{features: test.features, maxRecordCount: 4, noPagination: true, noObjectIDs: test.noObjectIDs}
+		server := httptest.NewServer(layer)
+		c := New(server.URL + "/FeatureServer/0")
+		buf := &bytes.Buffer{}
+		q := Query{Strategy: test.strategy, Geometry: test.geometry}
+		_, err := c.Convert(context.Background(), q, arcgis2geojson.Options{}, buf)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%+v: %v", test, err)
+		}
+		fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%+v: %v: %s", test, err, buf)
+		}
+		seen := map[float64]bool{}
+		for _, f := range fc.Features {
+			x := f.Geometry.(orb.Point)[0]
+			if seen[x] {
+				t.Errorf("%+v: feature at %v written twice", test, x)
+			}
+			seen[x] = true
+		}
+		if len(fc.Features) != test.expected {
+			t.Errorf("%+v: expected %d features, got %d", test, test.expected, len(fc.Features))
+		}
+		if layer.infoRequests != 1 {
+			t.Errorf("%+v: expected the layer's description to be fetched once, got %d requests", test, layer.infoRequests)
+		}
+	}
+}
+
+func TestConvertExtentPageSize(t *testing.T) {
+	requests := map[int]int{}
+	for _, pageSize := range []int{0, 2} {
+		layer := &fakeLayer{features: 25, maxRecordCount: 4, noPagination: true}
+		server := httptest.NewServer(layer)
+		features := 0
+		err := New(server.URL+"/FeatureServer/0").Pages(context.Background(), Query{Strategy: StrategyExtent, PageSize: pageSize}, func(data []byte) error {
+			p := &page{}
+			if err := json.Unmarshal(data, p); err != nil {
+				return err
+			}
+			if pageSize > 0 && len(p.Features) >= pageSize {
+				return fmt.Errorf("page of %d features with a page size of %d", len(p.Features), pageSize)
+			}
+			features += len(p.Features)
+			return nil
+		})
+		server.Close()
+		if err != nil {
+			t.Fatalf("page size %d: %v", pageSize, err)
+		}
+		if features != 25 {
+			t.Errorf("page size %d: expected 25 features, got %d", pageSize, features)
+		}
+		requests[pageSize] = len(layer.requests)
+	}
+	if requests[2] <= requests[0] {
+		t.Errorf("expected a smaller page size to split tiles more, got %v requests", requests)
+	}
+}
+
+func TestConvertIgnoredIDsOnly(t *testing.T) {
+	layer := &fakeLayer{features: 10, maxRecordCount: 4, noPagination: true, ignoreIDsOnly: true}
+	server := httptest.NewServer(layer)
+	defer server.Close()
+	c := New(server.URL + "/FeatureServer/0")
+
+	err := c.Pages(context.Background(), Query{Strategy: StrategyObjectIDs}, func([]byte) error { return nil })
+	var oe *objectIDsError
+	if !errors.As(err, &oe) {
+		t.Errorf("expected an object ids error, got %v", err)
+	}
+
+	// auto falls back to splitting the extent
+	buf := &bytes.Buffer{}
+	if _, err := c.Convert(context.Background(), Query{Strategy: StrategyAuto}, arcgis2geojson.Options{}, buf); err != nil {
+		t.Fatal(err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 10 {
+		t.Errorf("expected 10 features, got %d", len(fc.Features))
+	}
+}
+
+func TestLayerExtent(t *testing.T) {
+	tests := []struct {
+		extent   string
+		expected orb.Bound
+	}{
+		{``, world},
+		{`{"xmin": "NaN", "ymin": "NaN", "xmax": "NaN", "ymax": "NaN"}`, world},
+		{`{"xmin": 1, "ymin": 2, "xmax": 3, "ymax": 4, "spatialReference": {"wkid": 4326}}`, orb.Bound{Min: orb.Point{1, 2}, Max: orb.Point{3, 4}}},
+		{`{"xmin": -200, "ymin": 2, "xmax": 3, "ymax": 4, "spatialReference": {"wkid": 4326}}`, orb.Bound{Min: orb.Point{-180, 2}, Max: orb.Point{3, 4}}},
+		{`{"xmin": 0, "ymin": 0, "xmax": 20037508.342789244, "ymax": 20037508.342789244, "spatialReference": {"wkid": 102100, "latestWkid": 3857}}`, orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{180, 85.0511287798066}}},
+		{`{"xmin": 1, "ymin": 2, "xmax": 3, "ymax": 4, "spatialReference": {"wkid": 2927}}`, world},
+	}
+	for _, test := range tests {
+		b := layerExtent(json.RawMessage(test.extent))
+		for i := 0; i < 2; i++ {
+			if math.Abs(b.Min[i]-test.expected.Min[i]) > 1e-9 || math.Abs(b.Max[i]-test.expected.Max[i]) > 1e-9 {
+				t.Errorf("%s: expected %v, got %v", test.extent, test.expected, b)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+
+	"github.com/engelsjk/arcgis2geojson"
+	"github.com/paulmach/orb"
+)
+
+// maxSplitDepth is how many times a tile of the extent may be split into quadrants
+const maxSplitDepth = 20
+
+// world is the extent of wgs84 coordinates
+var world = orb.Bound{Min: orb.Point{-180, -90}, Max: orb.Point{180, 90}}
+
+// harvester fetches the features of an extent tile by tile
+type harvester struct {
+	c      *Client
+	params url.Values
+	fn     func(data []byte) error
+	// filter is the polygon or multipolygon of the query, which each tile is clipped to, or nil
+	filter orb.Geometry
+	// limit is the number of features that makes a tile split: the query's page size or the most
+	// features the layer returns at once, or 0 when neither is known
+	limit int
+	// seen are the keys of the features passed to fn
+	seen map[string]bool
+}
+
+// extentPages fetches the features matching q from tiles of the bound of q.Geometry, or the layer's
+// extent, splitting a tile into quadrants whenever the layer can't return all of its features at once,
+// or returns q.PageSize features or more. a polygon query geometry is clipped to each tile, so only
+// features intersecting it are fetched. features crossing tiles are only passed to fn the first time
+// they are fetched. info is the layer's description, fetched if it is nil.
+func (c *Client) extentPages(ctx context.Context, q Query, info *LayerInfo, fn func(data []byte) error) error {
+	params, err := q.values()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		if info, err = c.Info(ctx); err != nil {
+			return err
+		}
+	}
+	h := &harvester{c: c, params: params, fn: fn, limit: info.MaxRecordCount, seen: map[string]bool{}}
+	if q.PageSize > 0 && (h.limit == 0 || q.PageSize < h.limit) {
+		h.limit = q.PageSize
+	}
+	area := layerExtent(info.Extent)
+	switch g := q.Geometry.(type) {
+	case nil:
+	case orb.Bound:
+		area = g
+	default:
+		area, h.filter = g.Bound(), g
+	}
+	return h.harvest(ctx, area, 0)
+}
+
+// harvest fetches the features of a tile, splitting it when they don't all come back
+func (h *harvester) harvest(ctx context.Context, tile orb.Bound, depth int) error {
+	params := url.Values{}
+	for k, v := range h.params {
+		params[k] = v
+	}
+	var area orb.Geometry = tile
+	if h.filter != nil {
+		// a tile the polygon only touches has nothing to fetch, its neighbours cover the edge
+		if area, _ = arcgis2geojson.ClipGeometry(h.filter, tile); area == nil {
+			return nil
+		}
+	}
+	geometry, geometryType, err := esriGeometry(area)
+	if err != nil {
+		return err
+	}
+	params.Set("geometry", geometry)
+	params.Set("geometryType", geometryType)
+	params.Set("inSR", "4326")
+	params.Set("spatialRel", "esriSpatialRelIntersects")
+	response := map[string]json.RawMessage{}
+	data, err := h.c.request(ctx, "query", params, &response)
+	if err != nil {
+		return err
+	}
+	p := &page{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return err
+	}
+
+	if p.ExceededTransferLimit || (h.limit > 0 && len(p.Features) >= h.limit) {
+		if depth == maxSplitDepth {
+			return fmt.Errorf("error: tile %v still holds more features than the layer returns at once after %d splits", tile, depth)
+		}
+		center := tile.Center()
+		for _, quadrant := range []orb.Bound{
+			{Min: tile.Min, Max: center},
+			{Min: orb.Point{center[0], tile.Min[1]}, Max: orb.Point{tile.Max[0], center[1]}},
+			{Min: orb.Point{tile.Min[0], center[1]}, Max: orb.Point{center[0], tile.Max[1]}},
+			{Min: center, Max: tile.Max},
+		} {
+			if err := h.harvest(ctx, quadrant, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	field := objectIDField(data)
+	features := []json.RawMessage{}
+	for _, f := range p.Features {
+		key, err := featureKey(f, field)
+		if err != nil {
+			return err
+		}
+		if !h.seen[key] {
+			h.seen[key] = true
+			features = append(features, f)
+		}
+	}
+	if len(features) == 0 {
+		return nil
+	}
+	if len(features) != len(p.Features) {
+		b, err := json.Marshal(features)
+		if err != nil {
+			return err
+		}
+		response["features"] = b
+		if data, err = json.Marshal(response); err != nil {
+			return err
+		}
+	}
+	return h.fn(data)
+}
+
+// objectIDField returns the name of the object id field of a query response, or "" when it has none
+func objectIDField(data []byte) string {
+	meta := struct {
+		ObjectIDFieldName string `json:"objectIdFieldName"`
+		Fields            []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"fields"`
+	}{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return ""
+	}
+	if meta.ObjectIDFieldName != "" {
+		return meta.ObjectIDFieldName
+	}
+	for _, f := range meta.Fields {
+		if f.Type == "esriFieldTypeOID" {
+			return f.Name
+		}
+	}
+	return ""
+}
+
+// featureKey identifies a feature by its object id, or when it has none by a hash of its geometry
+// and attributes
+func featureKey(f json.RawMessage, field string) (string, error) {
+	feature := struct {
+		Attributes map[string]json.RawMessage `json:"attributes"`
+		Geometry   json.RawMessage            `json:"geometry"`
+	}{}
+	if err := json.Unmarshal(f, &feature); err != nil {
+		return "", err
+	}
+	if id, ok := feature.Attributes[field]; ok && field != "" && string(id) != "null" {
+		return "id:" + string(id), nil
+	}
+	// attributes are encoded with sorted keys, and the geometry compacted, so the same feature from
+	// two tiles hashes the same
+	attributes, err := json.Marshal(feature.Attributes)
+	if err != nil {
+		return "", err
+	}
+	geometry := &bytes.Buffer{}
+	if len(feature.Geometry) != 0 {
+		if err := json.Compact(geometry, feature.Geometry); err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256(append(append(geometry.Bytes(), 0), attributes...))
+	return fmt.Sprintf("hash:%x", sum), nil
+}
+
+// layerExtent returns the wgs84 bound of a layer's arcgis json extent, or the whole world when it is
+// missing, empty or in a spatial reference other than wgs84 or web mercator
+func layerExtent(raw json.RawMessage) orb.Bound {
+	extent := struct {
+		Xmin             float64 `json:"xmin"`
+		Ymin             float64 `json:"ymin"`
+		Xmax             float64 `json:"xmax"`
+		Ymax             float64 `json:"ymax"`
+		SpatialReference struct {
+			WKID       int `json:"wkid"`
+			LatestWKID int `json:"latestWkid"`
+		} `json:"spatialReference"`
+	}{}
+	if len(raw) == 0 || json.Unmarshal(raw, &extent) != nil || extent.Xmin > extent.Xmax || extent.Ymin > extent.Ymax {
+		return world
+	}
+	wkid := extent.SpatialReference.LatestWKID
+	if wkid == 0 {
+		wkid = extent.SpatialReference.WKID
+	}
+	min, max := orb.Point{extent.Xmin, extent.Ymin}, orb.Point{extent.Xmax, extent.Ymax}
+	switch wkid {
+	case 4326:
+	case 3857, 102100, 102113, 900913:
+		min, max = fromWebMercator(min), fromWebMercator(max)
+	default:
+		return world
+	}
+	b := orb.Bound{Min: min, Max: max}
+	if !b.Intersects(world) {
+		return world
+	}
+	return orb.Bound{
+		Min: orb.Point{math.Max(b.Min[0], -180), math.Max(b.Min[1], -90)},
+		Max: orb.Point{math.Min(b.Max[0], 180), math.Min(b.Max[1], 90)},
+	}
+}
+
+// fromWebMercator converts a web mercator point to wgs84
+func fromWebMercator(p orb.Point) orb.Point {
+	const radius = 6378137.0
+	lon := p[0] / radius * 180 / math.Pi
+	lat := (2*math.Atan(math.Exp(p[1]/radius)) - math.Pi/2) * 180 / math.Pi
+	return orb.Point{lon, lat}
+}
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/engelsjk/arcgis2geojson"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// Convert fetches the features matching q a page at a time, converts each page as configured by opts
+// and streams them to w as one geojson feature collection. it returns the reports of the pages merged
+// into one, with feature indexes counted across pages.
+//
+// duplicate ids are resolved across pages as features are written. DuplicateIDsKeepLast holds every
+// feature back until the last page is converted, and DuplicateIDsRewrite numbers rewritten ids from the
+// largest numeric id written so far rather than in the whole collection. the collection bbox is the union
+// of the feature bboxes. opts.Progress isn't called.
+//
+// when fetching or converting a page fails after features were written, the collection is still closed,
+// so w holds valid json with the features written so far, and the report covers the pages converted. it
+// is incomplete, and callers should discard it.
+func (c *Client) Convert(ctx context.Context, q Query, opts arcgis2geojson.Options, w io.Writer) (*arcgis2geojson.Report, error) {
+	start := time.Now()
+	opts.Progress = nil
+	report := &arcgis2geojson.Report{}
+	fw := newFeatureWriter(w, opts, report)
+	offset := 0
+	err := c.Pages(ctx, q, func(data []byte) error {
+		features, indexes, pageReport, err := arcgis2geojson.ConvertFeatures(ctx, data, opts)
+		if pageReport != nil {
+			mergeReport(report, pageReport, offset)
+		}
+		if err != nil {
+			return err
+		}
+		for i, f := range features {
+			if err := fw.write(f, offset+indexes[i]); err != nil {
+				return err
+			}
+		}
+		offset += pageReport.Stats.InputFeatures
+		return nil
+	})
+	if err == nil {
+		err = fw.close()
+	} else {
+		fw.abort()
+	}
+	report.Stats.Elapsed = time.Since(start)
+	return report, err
+}
+
+// featureWriter streams converted features to w as one feature collection, applying the duplicate id
+// policy across every feature written and counting them in the output statistics of report
+type featureWriter struct {
+	w        io.Writer
+	ids      *arcgis2geojson.DuplicateIDResolver
+	keepLast bool
+	bbox     bool
+	report   *arcgis2geojson.Report
+	started  bool
+
+	// features held back until close by DuplicateIDsKeepLast
+	held []heldFeature
+	// union of the feature bboxes
+	extent arcgis2geojson.BBoxUnion
+}
+
+type heldFeature struct {
+	feature *geojson.Feature
+	index   int
+}
+
+func newFeatureWriter(w io.Writer, opts arcgis2geojson.Options, report *arcgis2geojson.Report) *featureWriter {
+	return &featureWriter{
+		w:        w,
+		ids:      arcgis2geojson.NewDuplicateIDResolver(opts.DuplicateIDs),
+		keepLast: opts.DuplicateIDs == arcgis2geojson.DuplicateIDsKeepLast,
+		bbox:     opts.WriteBBox,
+		report:   report,
+	}
+}
+
+// write writes a feature, the index-th of the query, or drops it as the duplicate id policy asks
+func (fw *featureWriter) write(f *geojson.Feature, index int) error {
+	keep, err := fw.ids.Add(f, index)
+	if err != nil {
+		fw.report.DuplicateIDs = append(fw.report.DuplicateIDs, fw.ids.DuplicateIDs()...)
+		return err
+	}
+	switch {
+	case !keep:
+		return nil
+	case fw.keepLast:
+		fw.held = append(fw.held, heldFeature{f, index})
+		return nil
+	}
+	return fw.writeFeature(f)
+}
+
+// writeFeature writes a feature to the collection
+func (fw *featureWriter) writeFeature(f *geojson.Feature) error {
+	b, err := f.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	sep := ","
+	if !fw.started {
+		sep = `{"type":"FeatureCollection","features":[`
+		fw.started = true
+	}
+	if _, err := io.WriteString(fw.w, sep); err != nil {
+		return err
+	}
+	if _, err := fw.w.Write(b); err != nil {
+		return err
+	}
+	fw.report.Stats.AddOutputFeature(f)
+	if fw.bbox {
+		if len(f.BBox) == 4 {
+			fw.extent.Add(f.BBox)
+		} else {
+			fw.extent.AddGeometry(f.Geometry)
+		}
+	}
+	return nil
+}
+
+// close writes the features held back by DuplicateIDsKeepLast, records the duplicate ids in the report
+// and ends the feature collection with its bbox
+func (fw *featureWriter) close() error {
+	fw.report.DuplicateIDs = append(fw.report.DuplicateIDs, fw.ids.DuplicateIDs()...)
+	for _, h := range fw.held {
+		if !fw.ids.Last(h.feature, h.index) {
+			continue
+		}
+		if err := fw.writeFeature(h.feature); err != nil {
+			return err
+		}
+	}
+	if !fw.started {
+		_, err := io.WriteString(fw.w, `{"type":"FeatureCollection","features":[]}`+"\n")
+		return err
+	}
+	end := "]}\n"
+	if bbox := fw.extent.BBox(); bbox != nil {
+		b, err := json.Marshal(bbox)
+		if err != nil {
+			return err
+		}
+		end = `],"bbox":` + string(b) + "}\n"
+	}
+	_, err := io.WriteString(fw.w, end)
+	return err
+}
+
+// abort ends a collection cut short by an error, so what was written stays valid json
+func (fw *featureWriter) abort() {
+	if fw.started {
+		io.WriteString(fw.w, "]}\n")
+	}
+}
+
+// mergeReport adds the report of a page whose first feature is feature offset of the query to report
+func mergeReport(report, page *arcgis2geojson.Report, offset int) {
+	for _, d := range page.DuplicateIDs {
+		features := make([]int, len(d.Features))
+		for i, f := range d.Features {
+			features[i] = f + offset
+		}
+		d.Features = features
+		report.DuplicateIDs = append(report.DuplicateIDs, d)
+	}
+	for _, w := range page.Warnings {
+		if w.Feature >= 0 {
+			w.Feature += offset
+		}
+		report.Warnings = append(report.Warnings, w)
+	}
+	for _, r := range page.Repairs {
+		r.Feature += offset
+		report.Repairs = append(report.Repairs, r)
+	}
+
+	stats, p := &report.Stats, page.Stats
+	stats.InputFeatures += p.InputFeatures
+	stats.OutputFeatures += p.OutputFeatures
+	stats.InputGeometryTypes = addCounts(stats.InputGeometryTypes, p.InputGeometryTypes)
+	stats.OutputGeometryTypes = addCounts(stats.OutputGeometryTypes, p.OutputGeometryTypes)
+	stats.NullGeometries += p.NullGeometries
+	stats.RingsDropped += p.RingsDropped
+	stats.HolesPromoted += p.HolesPromoted
+	stats.InputVertices += p.InputVertices
+	stats.OutputVertices += p.OutputVertices
+	if p.Extent != nil {
+		extent := p.Extent.Bound()
+		if stats.Extent != nil {
+			extent = extent.Union(stats.Extent.Bound())
+		}
+		stats.Extent = geojson.NewBBox(extent)
+	}
+}
+
+// addCounts adds the counts of b to a
+func addCounts(a, b map[string]int) map[string]int {
+	if a == nil {
+		a = map[string]int{}
+	}
+	for k, n := range b {
+		a[k] += n
+	}
+	return a
+}
@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultConcurrency is the number of batches of object ids fetched at once by default
+const DefaultConcurrency = 4
+
+// defaultMaxRecordCount is the batch size used when neither the query nor the layer sets one
+const defaultMaxRecordCount = 1000
+
+// objectIDs is a returnIdsOnly query response. ObjectIDs is raw so that a missing member, from a server
+// that ignored returnIdsOnly and sent features instead, can be told apart from a null one.
+type objectIDs struct {
+	ObjectIDFieldName string          `json:"objectIdFieldName"`
+	ObjectIDs         json.RawMessage `json:"objectIds"`
+}
+
+// objectIDsError is a layer's refusal to return the object ids of a query, as from layers that don't
+// support returnIdsOnly
+type objectIDsError struct {
+	err error
+}
+
+func (e *objectIDsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *objectIDsError) Unwrap() error {
+	return e.err
+}
+
+// batch is a fetched batch of features, or the error fetching it
+type batch struct {
+	data []byte
+	err  error
+}
+
+// objectIDPages fetches the object ids of the features matching q, then the features in batches of
+// q.PageSize ids, or the layer's maxRecordCount, with up to q.Concurrency requests at once. batches
+// are passed to fn in object id order, each with its features sorted by object id. info is the layer's
+// description, fetched when needed if it is nil.
+func (c *Client) objectIDPages(ctx context.Context, q Query, info *LayerInfo, fn func(data []byte) error) error {
+	params, err := q.values()
+	if err != nil {
+		return err
+	}
+	ids, field, err := c.objectIDs(ctx, params)
+	if err != nil {
+		return err
+	}
+	size := q.PageSize
+	if size <= 0 {
+		if info == nil {
+			if info, err = c.Info(ctx); err != nil {
+				return err
+			}
+		}
+		size = info.MaxRecordCount
+		if size <= 0 {
+			size = defaultMaxRecordCount
+		}
+	}
+	concurrency := q.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	// the ids already select the features
+	params.Del("where")
+	for _, k := range []string{"geometry", "geometryType", "inSR", "spatialRel"} {
+		params.Del(k)
+	}
+
+	batches := [][]int64{}
+	for start := 0; start < len(ids); start += size {
+		end := start + size
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batches = append(batches, ids[start:end])
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]chan batch, len(batches))
+	for i := range results {
+		results[i] = make(chan batch, 1)
+	}
+	// a slot is taken before a batch is requested and given back once it is passed to fn, so at most
+	// concurrency batches are in flight or waiting for the batches before them
+	slots := make(chan struct{}, concurrency)
+	go func() {
+		for i, ids := range batches {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, ids []int64) {
+				data, err := c.objectIDBatch(ctx, params, ids, field)
+				results[i] <- batch{data, err}
+			}(i, ids)
+		}
+	}()
+	for i := range results {
+		var b batch
+		select {
+		case b = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-slots
+		if b.err != nil {
+			return b.err
+		}
+		if err := fn(b.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectIDs fetches the sorted object ids of the features matching the query params, and the name of
+// the object id field. server errors and responses without ids are returned as *objectIDsError.
+func (c *Client) objectIDs(ctx context.Context, params url.Values) ([]int64, string, error) {
+	idParams := url.Values{}
+	for k, v := range params {
+		idParams[k] = v
+	}
+	idParams.Set("returnIdsOnly", "true")
+	idParams.Del("orderByFields")
+	response := &objectIDs{}
+	if _, err := c.request(ctx, "query", idParams, response); err != nil {
+		if se := (*ServerError)(nil); errors.As(err, &se) {
+			return nil, "", &objectIDsError{err}
+		}
+		return nil, "", err
+	}
+	if response.ObjectIDFieldName == "" {
+		return nil, "", &objectIDsError{fmt.Errorf("error: request %s: the response has no objectIdFieldName", c.URL)}
+	}
+	if response.ObjectIDs == nil {
+		return nil, "", &objectIDsError{fmt.Errorf("error: request %s: the response has no objectIds, returnIdsOnly was ignored", c.URL)}
+	}
+	ids := []int64{}
+	if err := json.Unmarshal(response.ObjectIDs, &ids); err != nil {
+		return nil, "", fmt.Errorf("error: request %s: invalid objectIds: %v", c.URL, err)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, response.ObjectIDFieldName, nil
+}
+
+// objectIDBatch fetches the features with ids, sorted by their object id field
+func (c *Client) objectIDBatch(ctx context.Context, params url.Values, ids []int64, field string) ([]byte, error) {
+	batchParams := url.Values{}
+	for k, v := range params {
+		batchParams[k] = v
+	}
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	batchParams.Set("objectIds", strings.Join(s, ","))
+	response := map[string]json.RawMessage{}
+	data, err := c.request(ctx, "query", batchParams, &response)
+	if err != nil {
+		return nil, err
+	}
+	p := &page{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	if p.ExceededTransferLimit {
+		return nil, fmt.Errorf("error: a batch of %d object ids exceeded the transfer limit of %s, use a smaller page size", len(ids), c.URL)
+	}
+	return sortFeatures(response, field)
+}
+
+// sortFeatures sorts the features of a query response by their object id and encodes the response
+func sortFeatures(response map[string]json.RawMessage, field string) ([]byte, error) {
+	features := []struct {
+		Attributes map[string]json.RawMessage `json:"attributes"`
+	}{}
+	raw := []json.RawMessage{}
+	if len(response["features"]) != 0 {
+		if err := json.Unmarshal(response["features"], &raw); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(response["features"], &features); err != nil {
+			return nil, err
+		}
+	}
+	keys := make([]int64, len(raw))
+	for i, f := range features {
+		// features without a numeric object id go last
+		keys[i] = math.MaxInt64
+		if id, err := strconv.ParseInt(string(f.Attributes[field]), 10, 64); err == nil {
+			keys[i] = id
+		}
+	}
+	order := make([]int, len(raw))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return keys[order[a]] < keys[order[b]] })
+	sorted := make([]json.RawMessage, len(raw))
+	for i, j := range order {
+		sorted[i] = raw[j]
+	}
+	b, err := json.Marshal(sorted)
+	if err != nil {
+		return nil, err
+	}
+	response["features"] = b
+	return json.Marshal(response)
+}
@@ -0,0 +1,614 @@
+package arcgis2geojson
+
+import (
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/clip"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// DefaultClipProperty is the property set to true on features cut by clipping
+const DefaultClipProperty = "clipped"
+
+// clipProperty returns the property flagging clipped features
+func (c *converter) clipProperty() string {
+	if c.opts.ClipProperty != "" {
+		return c.opts.ClipProperty
+	}
+	return DefaultClipProperty
+}
+
+// clipFeature clips the geometry, or the bbox of a feature without a geometry, to the clip area. it
+// reports whether anything is left and whether anything was cut away.
+func (c *converter) clipFeature(feature *geojson.Feature) (kept, clipped bool) {
+	if feature.Geometry == nil {
+		if len(feature.BBox) != 4 {
+			return false, false
+		}
+		return clipBBox(feature, c.opts.Clip.Bound())
+	}
+	g, clipped := ClipGeometry(feature.Geometry, c.opts.Clip)
+	if g == nil {
+		return false, true
+	}
+	feature.Geometry = g
+	return true, clipped
+}
+
+// clipBBox clips the bbox of a feature without a geometry to a bound
+func clipBBox(feature *geojson.Feature, b orb.Bound) (kept, clipped bool) {
+	var clippedBounds []orb.Bound
+	for _, bound := range bboxBounds(feature.BBox) {
+		if !bound.Intersects(b) {
+			clipped = true
+			continue
+		}
+		if !boundContains(b, bound, bboxTolerance) {
+			bound = clip.Bound(b, bound)
+			clipped = true
+		}
+		clippedBounds = append(clippedBounds, bound)
+	}
+	if len(clippedBounds) == 0 {
+		return false, true
+	}
+	if clipped {
+		bound := clippedBounds[0]
+		if len(clippedBounds) == 2 {
+			// still crossing the antimeridian
+			bound = orb.Bound{Min: orb.Point{bound.Min[0], bound.Min[1]}, Max: orb.Point{clippedBounds[1].Max[0], bound.Max[1]}}
+		}
+		feature.BBox = geojson.NewBBox(bound)
+	}
+	return true, clipped
+}
+
+// ClipGeometry clips g to the area of area, which may be an orb.Bound, orb.Polygon or orb.MultiPolygon.
+// it returns nil when nothing of g is inside the area, and reports whether anything was cut away.
+// lines are clipped to bounds with orb's clip package, polygons are intersected ring by ring so holes
+// crossing the edge of the area are cut too. a geometry clipped by several overlapping polygons may
+// overlap itself.
+func ClipGeometry(g orb.Geometry, area orb.Geometry) (orb.Geometry, bool) {
+	if g == nil || area == nil {
+		return nil, false
+	}
+	polygons := filterPolygons(area)
+	if len(polygons) == 0 || !g.Bound().Intersects(area.Bound()) {
+		return nil, true
+	}
+	if b, ok := area.(orb.Bound); ok {
+		switch g.(type) {
+		case orb.Point, orb.MultiPoint, orb.LineString, orb.MultiLineString:
+			clipped := clip.Geometry(b, orb.Clone(g))
+			return clipped, clipped == nil || !orb.Equal(clipped, g)
+		}
+	}
+
+	switch t := g.(type) {
+	case orb.Point:
+		if clipContainsPoint(polygons, t) {
+			return t, false
+		}
+		return nil, true
+	case orb.MultiPoint:
+		kept := orb.MultiPoint{}
+		for _, pt := range t {
+			if clipContainsPoint(polygons, pt) {
+				kept = append(kept, pt)
+			}
+		}
+		return multiPoint(kept), len(kept) != len(t)
+	case orb.LineString:
+		lines, clipped := clipLineString(t, polygons)
+		return multiLineString(lines), clipped
+	case orb.MultiLineString:
+		lines, clipped := orb.MultiLineString{}, false
+		for _, ls := range t {
+			l, c := clipLineString(ls, polygons)
+			lines, clipped = append(lines, l...), clipped || c
+		}
+		return multiLineString(lines), clipped
+	case orb.Polygon:
+		result, clipped := clipPolygon(t, polygons)
+		return multiPolygon(result), clipped
+	case orb.MultiPolygon:
+		result, clipped := orb.MultiPolygon{}, false
+		for _, p := range t {
+			r, c := clipPolygon(p, polygons)
+			result, clipped = append(result, r...), clipped || c
+		}
+		return multiPolygon(result), clipped
+	}
+	return g, false
+}
+
+func clipContainsPoint(polygons []orb.Polygon, pt orb.Point) bool {
+	for _, p := range polygons {
+		if polygonContainsPoint(p, []float64{pt[0], pt[1]}) {
+			return true
+		}
+	}
+	return false
+}
+
+func multiPoint(mp orb.MultiPoint) orb.Geometry {
+	switch len(mp) {
+	case 0:
+		return nil
+	case 1:
+		return mp[0]
+	}
+	return mp
+}
+
+func multiLineString(mls orb.MultiLineString) orb.Geometry {
+	switch len(mls) {
+	case 0:
+		return nil
+	case 1:
+		return mls[0]
+	}
+	return mls
+}
+
+func multiPolygon(mp orb.MultiPolygon) orb.Geometry {
+	switch len(mp) {
+	case 0:
+		return nil
+	case 1:
+		return mp[0]
+	}
+	return mp
+}
+
+///////////////////////////////////////////////////////////////////////////////////////
+// edges
+
+// edge is a directed segment of a line or ring
+type edge [2]orb.Point
+
+func (e edge) reversed() edge {
+	return edge{e[1], e[0]}
+}
+
+func (e edge) midpoint() []float64 {
+	return []float64{(e[0][0] + e[1][0]) / 2, (e[0][1] + e[1][1]) / 2}
+}
+
+// pathEdges returns the edges of a path, skipping repeated points
+func pathEdges(path []orb.Point) []edge {
+	edges := []edge{}
+	for i := 1; i < len(path); i++ {
+		if path[i] != path[i-1] {
+			edges = append(edges, edge{path[i-1], path[i]})
+		}
+	}
+	return edges
+}
+
+// polygonEdges returns the edges of the rings of a polygon, with the outer ring counterclockwise and
+// holes clockwise so the inside of the polygon is always to the left of an edge
+func polygonEdges(p orb.Polygon) []edge {
+	edges := []edge{}
+	for i, r := range p {
+		if len(r) < minRingPoints {
+			continue
+		}
+		want := orb.CW
+		if i == 0 {
+			want = orb.CCW
+		}
+		if r.Orientation() != want {
+			r = r.Clone()
+			r.Reverse()
+		}
+		edges = append(edges, pathEdges(r)...)
+	}
+	return edges
+}
+
+func cross(a, b orb.Point) float64 {
+	return a[0]*b[1] - a[1]*b[0]
+}
+
+func sub(a, b orb.Point) orb.Point {
+	return orb.Point{a[0] - b[0], a[1] - b[1]}
+}
+
+// splitEdges splits the edges of a and b at their intersections with each other. intersection points
+// are computed once and shared, so the pieces of both sets join up exactly.
+func splitEdges(a, b []edge) ([]edge, []edge) {
+	cuts := make([][]orb.Point, len(a)+len(b))
+	overlappingEdges(append(a[:len(a):len(a)], b...), func(i, j int) {
+		if i >= len(a) || j < len(a) {
+			// both from the same set
+			return
+		}
+		onP, onQ := edgeCuts(a[i], b[j-len(a)])
+		cuts[i] = append(cuts[i], onP...)
+		cuts[j] = append(cuts[j], onQ...)
+	})
+	snapCuts(append(a[:len(a):len(a)], b...), cuts)
+	return cutEdges(a, cuts[:len(a)]), cutEdges(b, cuts[len(a):])
+}
+
+// splitSelf splits edges at their intersections with each other
+func splitSelf(edges []edge) []edge {
+	cuts := make([][]orb.Point, len(edges))
+	overlappingEdges(edges, func(i, j int) {
+		onP, onQ := edgeCuts(edges[i], edges[j])
+		cuts[i] = append(cuts[i], onP...)
+		cuts[j] = append(cuts[j], onQ...)
+	})
+	snapCuts(edges, cuts)
+	return cutEdges(edges, cuts)
+}
+
+// snapTolerance is how close, relative to the largest coordinate, cut points are snapped together
+const snapTolerance = 1e-10
+
+// snapCuts snaps each cut point to an end of an edge or an earlier cut point within snapTolerance.
+// where three or more edges cross at a point, each pair works out a slightly different crossing, and
+// snapping makes them the same point again.
+func snapCuts(edges []edge, cuts [][]orb.Point) {
+	scale := 1.0
+	for _, e := range edges {
+		for _, pt := range e {
+			scale = math.Max(scale, math.Max(math.Abs(pt[0]), math.Abs(pt[1])))
+		}
+	}
+	tolerance := scale * snapTolerance
+	type cell [2]int64
+	cellOf := func(pt orb.Point) cell {
+		return cell{int64(math.Floor(pt[0] / tolerance)), int64(math.Floor(pt[1] / tolerance))}
+	}
+	grid := map[cell][]orb.Point{}
+	add := func(pt orb.Point) {
+		c := cellOf(pt)
+		for _, other := range grid[c] {
+			if other == pt {
+				return
+			}
+		}
+		grid[c] = append(grid[c], pt)
+	}
+	snap := func(pt orb.Point) orb.Point {
+		c := cellOf(pt)
+		for dx := int64(-1); dx <= 1; dx++ {
+			for dy := int64(-1); dy <= 1; dy++ {
+				for _, other := range grid[cell{c[0] + dx, c[1] + dy}] {
+					if math.Abs(other[0]-pt[0]) <= tolerance && math.Abs(other[1]-pt[1]) <= tolerance {
+						return other
+					}
+				}
+			}
+		}
+		add(pt)
+		return pt
+	}
+	for _, e := range edges {
+		add(e[0])
+		add(e[1])
+	}
+	for i := range cuts {
+		for k, pt := range cuts[i] {
+			cuts[i][k] = snap(pt)
+		}
+	}
+}
+
+// edgeCuts returns the points where edges p and q cut each other: their crossing, or the ends of each
+// that lie on the other when they are collinear
+func edgeCuts(p, q edge) (onP, onQ []orb.Point) {
+	if !(orb.Bound{Min: p[0], Max: p[0]}).Extend(p[1]).Intersects(orb.Bound{Min: q[0], Max: q[0]}.Extend(q[1])) {
+		return nil, nil
+	}
+	r, s := sub(p[1], p[0]), sub(q[1], q[0])
+	qp := sub(q[0], p[0])
+	denom := cross(r, s)
+	if denom == 0 {
+		if cross(qp, r) != 0 {
+			// parallel
+			return nil, nil
+		}
+		for _, pt := range q {
+			if onEdge(p, pt) {
+				onP = append(onP, pt)
+			}
+		}
+		for _, pt := range p {
+			if onEdge(q, pt) {
+				onQ = append(onQ, pt)
+			}
+		}
+		return onP, onQ
+	}
+	t := cross(qp, s) / denom
+	u := cross(qp, r) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return nil, nil
+	}
+	var pt orb.Point
+	switch {
+	case t == 0:
+		pt = p[0]
+	case t == 1:
+		pt = p[1]
+	case u == 0:
+		pt = q[0]
+	case u == 1:
+		pt = q[1]
+	default:
+		pt = crossing(p, q)
+	}
+	return []orb.Point{pt}, []orb.Point{pt}
+}
+
+// crossing returns the point where two edges cross. it is worked out from the edges in a fixed order
+// and direction, so every pair of edges covering the same two segments gets exactly the same point.
+func crossing(p, q edge) orb.Point {
+	p, q = undirected(p), undirected(q)
+	if edgeLess(q, p) {
+		p, q = q, p
+	}
+	r, s := sub(p[1], p[0]), sub(q[1], q[0])
+	t := cross(sub(q[0], p[0]), s) / cross(r, s)
+	return orb.Point{p[0][0] + t*r[0], p[0][1] + t*r[1]}
+}
+
+// edgeLess orders edges by their first point, then their second
+func edgeLess(p, q edge) bool {
+	for i := range p {
+		if p[i] != q[i] {
+			return p[i][0] < q[i][0] || (p[i][0] == q[i][0] && p[i][1] < q[i][1])
+		}
+	}
+	return false
+}
+
+// onEdge reports whether a point collinear with an edge lies strictly between its ends
+func onEdge(e edge, pt orb.Point) bool {
+	d := sub(e[1], e[0])
+	t := (pt[0]-e[0][0])*d[0] + (pt[1]-e[0][1])*d[1]
+	return t > 0 && t < d[0]*d[0]+d[1]*d[1]
+}
+
+// cutEdges splits each edge at its cut points, in order along the edge
+func cutEdges(edges []edge, cuts [][]orb.Point) []edge {
+	pieces := []edge{}
+	for i, e := range edges {
+		if len(cuts[i]) == 0 {
+			pieces = append(pieces, e)
+			continue
+		}
+		d := sub(e[1], e[0])
+		along := func(pt orb.Point) float64 {
+			return (pt[0]-e[0][0])*d[0] + (pt[1]-e[0][1])*d[1]
+		}
+		points := append(cuts[i], e[1])
+		sort.SliceStable(points, func(a, b int) bool { return along(points[a]) < along(points[b]) })
+		from := e[0]
+		for _, pt := range points {
+			if pt != from {
+				pieces = append(pieces, edge{from, pt})
+				from = pt
+			}
+		}
+	}
+	return pieces
+}
+
+///////////////////////////////////////////////////////////////////////////////////////
+// lines
+
+// clipLineString returns the parts of a line string inside the polygons
+func clipLineString(ls orb.LineString, polygons []orb.Polygon) (orb.MultiLineString, bool) {
+	result := orb.MultiLineString{}
+	for _, polygon := range polygons {
+		pieces, boundary := splitEdges(pathEdges(ls), polygonEdges(polygon))
+		onBoundary := map[edge]bool{}
+		for _, e := range boundary {
+			onBoundary[e], onBoundary[e.reversed()] = true, true
+		}
+		cut := false
+		var line orb.LineString
+		flush := func() {
+			if len(line) > 1 {
+				result = append(result, line)
+			}
+			line = nil
+		}
+		for _, e := range pieces {
+			if !onBoundary[e] && !polygonContainsPoint(polygon, e.midpoint()) {
+				cut = true
+				flush()
+				continue
+			}
+			if line == nil {
+				line = orb.LineString{e[0]}
+			}
+			line = append(line, e[1])
+		}
+		if !cut {
+			// entirely inside, keep the line as it was
+			return orb.MultiLineString{ls}, false
+		}
+		flush()
+	}
+	return result, true
+}
+
+///////////////////////////////////////////////////////////////////////////////////////
+// polygons
+
+// clipPolygon intersects a polygon with each clip polygon. the edges of both are split where they cross,
+// and the intersection is made of the subject edges inside the clip polygon and the clip edges inside
+// the subject, chained back into rings.
+func clipPolygon(p orb.Polygon, polygons []orb.Polygon) (orb.MultiPolygon, bool) {
+	if len(p) == 0 {
+		return nil, false
+	}
+	result := orb.MultiPolygon{}
+	for _, polygon := range polygons {
+		if !p.Bound().Intersects(polygon.Bound()) {
+			continue
+		}
+		subject, clipping := splitEdges(polygonEdges(p), polygonEdges(polygon))
+		subjectEdges := map[edge]bool{}
+		for _, e := range subject {
+			subjectEdges[e] = true
+		}
+		clippingEdges := map[edge]bool{}
+		for _, e := range clipping {
+			clippingEdges[e] = true
+		}
+
+		kept := []edge{}
+		changed := false
+		for _, e := range subject {
+			switch {
+			case clippingEdges[e]:
+				// shared boundary with the inside on the same side
+				kept = append(kept, e)
+			case clippingEdges[e.reversed()] || !polygonContainsPoint(polygon, e.midpoint()):
+				changed = true
+			default:
+				kept = append(kept, e)
+			}
+		}
+		for _, e := range clipping {
+			if subjectEdges[e] || subjectEdges[e.reversed()] || !polygonContainsPoint(p, e.midpoint()) {
+				continue
+			}
+			kept = append(kept, e)
+			changed = true
+		}
+		if !changed {
+			// entirely inside, keep the polygon as it was
+			return orb.MultiPolygon{p}, false
+		}
+		result = append(result, assembleRings(kept)...)
+	}
+	return result, true
+}
+
+// assembleRings chains edges into closed rings and groups them into polygons. where several edges
+// leave a point the ring takes the edge with the smallest clockwise angle from the edge it arrived by,
+// the sharpest left turn, so rings touching at a point stay separate. a ring that comes back to a point
+// it already passed is split there into two rings. counterclockwise rings are outer rings and clockwise
+// rings are holes of the smallest outer ring containing them.
+func assembleRings(edges []edge) orb.MultiPolygon {
+	from := map[orb.Point][]int{}
+	for i, e := range edges {
+		from[e[0]] = append(from[e[0]], i)
+	}
+	used := make([]bool, len(edges))
+	next := func(in edge) int {
+		best, bestTurn := -1, 0.0
+		d := sub(in[1], in[0])
+		for _, i := range from[in[1]] {
+			if used[i] {
+				continue
+			}
+			o := sub(edges[i][1], edges[i][0])
+			turn := math.Atan2(cross(d, o), d[0]*o[0]+d[1]*o[1])
+			if best < 0 || turn > bestTurn {
+				best, bestTurn = i, turn
+			}
+		}
+		return best
+	}
+
+	shells, holes := []orb.Ring{}, []orb.Ring{}
+	add := func(ring orb.Ring) {
+		if len(ring) < minRingPoints {
+			// collapsed
+			return
+		}
+		switch ring.Orientation() {
+		case orb.CCW:
+			shells = append(shells, ring)
+		case orb.CW:
+			holes = append(holes, ring)
+		}
+	}
+	for i := range edges {
+		if used[i] {
+			continue
+		}
+		start := edges[i][0]
+		ring := orb.Ring{start}
+		// where each point of the ring is in it
+		at := map[orb.Point]int{start: 0}
+		closed := false
+		for j := i; j >= 0; j = next(edges[j]) {
+			used[j] = true
+			pt := edges[j][1]
+			if pt == start {
+				closed = true
+				break
+			}
+			if k, ok := at[pt]; ok {
+				// back at a point the ring passed, the loop since then is a ring of its own
+				loop := append(orb.Ring{}, ring[k:]...)
+				add(append(loop, pt))
+				for _, looped := range ring[k+1:] {
+					delete(at, looped)
+				}
+				ring = ring[:k+1]
+				continue
+			}
+			at[pt] = len(ring)
+			ring = append(ring, pt)
+		}
+		if !closed {
+			// left open by rounding
+			continue
+		}
+		add(append(ring, start))
+	}
+
+	// smallest shells first, so holes go to the innermost shell containing them
+	sort.SliceStable(shells, func(a, b int) bool { return ringArea(shells[a]) < ringArea(shells[b]) })
+	polygons := make(orb.MultiPolygon, len(shells))
+	for i, shell := range shells {
+		polygons[i] = orb.Polygon{shell}
+	}
+	for _, hole := range holes {
+		for i, shell := range shells {
+			if ringContainsRing(shell, hole) {
+				polygons[i] = append(polygons[i], hole)
+				break
+			}
+		}
+	}
+	return polygons
+}
+
+// ringContainsRing reports whether inner is inside outer, where the rings may touch but not cross
+func ringContainsRing(outer, inner orb.Ring) bool {
+	if !outer.Bound().Contains(inner.Bound().Min) || !outer.Bound().Contains(inner.Bound().Max) {
+		return false
+	}
+	for i := 1; i < len(inner); i++ {
+		pt := edge{inner[i-1], inner[i]}.midpoint()
+		if !onRing(outer, orb.Point{pt[0], pt[1]}) {
+			return coordinatesContainPoint(toCoordinates(outer), pt)
+		}
+	}
+	return false
+}
+
+// ringArea returns the planar area of a ring
+func ringArea(r orb.Ring) float64 {
+	area := 0.0
+	for i := 1; i < len(r); i++ {
+		area += cross(r[i-1], r[i])
+	}
+	if area < 0 {
+		area = -area
+	}
+	return area / 2
+}
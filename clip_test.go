@@ -0,0 +1,107 @@
+package arcgis2geojson
+
+import (
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+func TestClipGeometry(t *testing.T) {
+	// a square with a hole in the middle
+	square := orb.Polygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}},
+	}
+	tests := []struct {
+		name    string
+		g       orb.Geometry
+		area    orb.Geometry
+		geoType string
+		rings   int
+		measure float64
+		clipped bool
+	}{
+		// the hole crosses the clip edge and becomes a notch in the outer ring
+		{"polygon by bound", square, orb.Bound{Min: orb.Point{5, -5}, Max: orb.Point{15, 15}}, "Polygon", 1, 48, true},
+		{"polygon by polygon", square, orb.Polygon{{{5, -5}, {15, -5}, {15, 15}, {5, 15}, {5, -5}}}, "Polygon", 1, 48, true},
+		{"hole kept", square, orb.Bound{Min: orb.Point{1, 1}, Max: orb.Point{9, 9}}, "Polygon", 2, 60, true},
+		{"split by a hole", orb.Polygon{{{3, 4.5}, {7, 4.5}, {7, 5.5}, {3, 5.5}, {3, 4.5}}}, square, "MultiPolygon", 2, 2, true},
+		{"inside", square, orb.Bound{Min: orb.Point{-1, -1}, Max: orb.Point{11, 11}}, "Polygon", 2, 96, false},
+		{"line by bound", orb.LineString{{-5, 5}, {15, 5}}, orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{10, 10}}, "LineString", 0, 10, true},
+		{"line by polygon", orb.LineString{{-5, 5}, {15, 5}}, square, "MultiLineString", 0, 8, true},
+		{"line along the edge", orb.LineString{{0, 0}, {10, 0}}, square, "LineString", 0, 10, false},
+		{"multipoint", orb.MultiPoint{{1, 1}, {5, 5}, {20, 20}}, square, "Point", 0, 0, true},
+	}
+	for _, test := range tests {
+		g, clipped := ClipGeometry(orb.Clone(test.g), test.area)
+		if g == nil {
+			t.Errorf("%s: expected a geometry", test.name)
+			continue
+		}
+		if g.GeoJSONType() != test.geoType {
+			t.Errorf("%s: expected %s, got %s", test.name, test.geoType, g.GeoJSONType())
+		}
+		if clipped != test.clipped {
+			t.Errorf("%s: expected clipped %v", test.name, test.clipped)
+		}
+		measure := planar.Length(g)
+		if test.rings > 0 {
+			measure = planar.Area(g)
+			rings := 0
+			for _, p := range geometryPolygons(g) {
+				rings += len(p)
+			}
+			if rings != test.rings {
+				t.Errorf("%s: expected %d rings, got %d", test.name, test.rings, rings)
+			}
+		}
+		if math.Abs(measure-test.measure) > 1e-9 {
+			t.Errorf("%s: expected %v, got %v", test.name, test.measure, measure)
+		}
+	}
+
+	if g, _ := ClipGeometry(orb.Polygon{{{4.5, 4.5}, {5.5, 4.5}, {5.5, 5.5}, {4.5, 5.5}, {4.5, 4.5}}}, square); g != nil {
+		t.Errorf("expected nothing left of a polygon in the hole, got %v", g)
+	}
+	if g, _ := ClipGeometry(orb.LineString{{20, 20}, {30, 30}}, square); g != nil {
+		t.Errorf("expected nothing left of a line outside, got %v", g)
+	}
+}
+
+func TestConvertClip(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1, 1]]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"paths": [[[5, -5], [5, 5]]]}},
+			{"attributes": {"OBJECTID": 3}, "geometry": {"rings": [[[20, 20], [20, 21], [21, 21], [21, 20], [20, 20]]]}},
+			{"attributes": {"OBJECTID": 4}, "xmin": 8, "ymin": 8, "xmax": 12, "ymax": 12}
+		]
+	}`
+	fc := convertFeatureCollection(t, data, Options{Clip: orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{10, 10}}})
+	if len(fc.Features) != 3 {
+		t.Fatalf("expected 3 features, got %d", len(fc.Features))
+	}
+	if _, ok := fc.Features[0].Properties[DefaultClipProperty]; ok {
+		t.Error("expected feature 1 not to be flagged")
+	}
+	if fc.Features[1].Properties[DefaultClipProperty] != true || planar.Length(fc.Features[1].Geometry) != 5 {
+		t.Errorf("expected feature 2 to be clipped, got %v", fc.Features[1].Geometry)
+	}
+	if b := fc.Features[2].Geometry.Bound(); b.Max != (orb.Point{10, 10}) {
+		t.Errorf("expected envelope to be clipped, got %v", b)
+	}
+
+	raw := convertRawFeatureCollection(t, data, Options{
+		Clip:            orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{10, 10}},
+		ClipProperty:    "cut",
+		EnvelopesAsBBox: true,
+		WriteBBox:       true,
+	})
+	last := raw.Features[len(raw.Features)-1]
+	if last.Properties["cut"] != true || last.BBox[2] != 10 || last.BBox[3] != 10 {
+		t.Errorf("expected clipped bbox, got %v %v", last.BBox, last.Properties)
+	}
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/engelsjk/arcgis2geojson"
+	"github.com/engelsjk/arcgis2geojson/client"
+	"github.com/paulmach/orb"
 	"github.com/urfave/cli/v2"
 )
 
@@ -18,44 +27,630 @@ func main() {
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "id",
-				Usage: "id attribute key (default `OBJECTID`)",
+				Usage: "id attribute key, or keys joined with + for a composite id (default `OBJECTID`)",
+			},
+			&cli.StringFlag{
+				Name:  "id-strategy",
+				Usage: "how to pick feature ids: auto, attribute, oid, objectidfieldname or hash",
+				Value: "auto",
+			},
+			&cli.StringFlag{
+				Name:  "id-prefix",
+				Usage: "prefix prepended to feature ids",
+			},
+			&cli.StringFlag{
+				Name:  "id-type",
+				Usage: "force feature ids to auto, string or number",
+				Value: "auto",
+			},
+			&cli.StringFlag{
+				Name:  "duplicate-ids",
+				Usage: "what to do with features sharing an id: keep, error, first, last or rewrite",
+				Value: "keep",
+			},
+			&cli.StringFlag{
+				Name:  "invalid-features",
+				Usage: "what to do with features that fail to convert: error, skip or null (keep them with a null geometry)",
+				Value: "error",
+			},
+			&cli.BoolFlag{
+				Name:  "stats",
+				Usage: "print conversion statistics to stderr",
+			},
+			&cli.StringFlag{
+				Name:  "stats-format",
+				Usage: "format of the --stats output: text or json",
+				Value: "text",
+			},
+			&cli.StringFlag{
+				Name:  "report",
+				Usage: "write the conversion report, with its warnings and repairs, as json to `FILE`",
+			},
+			&cli.BoolFlag{
+				Name:  "aliases",
+				Usage: "key properties by field alias instead of field name",
+			},
+			&cli.StringFlag{
+				Name:  "alias-collision",
+				Usage: "how to resolve fields sharing an alias: suffix, name or error",
+				Value: "suffix",
+			},
+			&cli.BoolFlag{
+				Name:  "coerce",
+				Usage: "convert attribute values to the type of their field",
+			},
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "json or yaml field mapping `FILE`",
+			},
+			&cli.StringSliceFlag{
+				Name:  "include",
+				Usage: "only keep fields matching the glob `PATTERN`",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "drop fields matching the glob `PATTERN`",
+			},
+			&cli.BoolFlag{
+				Name:  "drop-system-fields",
+				Usage: "drop esri system and editor tracking fields",
+			},
+			&cli.StringFlag{
+				Name:  "where",
+				Usage: "only keep features matching the sql `CLAUSE`, e.g. \"CTYNAME = 'RENTON'\"",
+			},
+			&cli.StringFlag{
+				Name:  "bbox",
+				Usage: "only keep features intersecting the bounding box `XMIN,YMIN,XMAX,YMAX`",
+			},
+			&cli.StringFlag{
+				Name:  "within",
+				Usage: "only keep features intersecting the polygons of a geojson `FILE`",
+			},
+			&cli.BoolFlag{
+				Name:  "make-valid",
+				Usage: "repair geometries into valid simple features",
+			},
+			&cli.BoolFlag{
+				Name:  "split-antimeridian",
+				Usage: "split lines and polygons crossing the antimeridian",
+			},
+			&cli.StringFlag{
+				Name:  "clip",
+				Usage: "clip geometries to the bounding box `XMIN,YMIN,XMAX,YMAX`",
+			},
+			&cli.StringFlag{
+				Name:  "clip-to",
+				Usage: "clip geometries to the polygons of a geojson `FILE`",
+			},
+			&cli.StringFlag{
+				Name:  "clip-property",
+				Usage: "property set to true on clipped features",
+				Value: arcgis2geojson.DefaultClipProperty,
+			},
+			&cli.StringFlag{
+				Name:  "simplify",
+				Usage: "simplify geometries with none, douglas-peucker or visvalingam",
+				Value: "none",
+			},
+			&cli.Float64Flag{
+				Name:  "simplify-tolerance",
+				Usage: "simplification tolerance in `DEGREES`",
+			},
+			&cli.IntFlag{
+				Name:  "precision",
+				Usage: "round coordinates to `N` decimal places, at most 15",
+			},
+			&cli.Float64Flag{
+				Name:  "precision-meters",
+				Usage: "round coordinates to the fewest decimal places accurate to `M` meters",
+			},
+			&cli.BoolFlag{
+				Name:  "envelopes-as-bbox",
+				Usage: "keep envelopes as a bbox member with a null geometry instead of a polygon",
+			},
+			&cli.BoolFlag{
+				Name:  "write-bbox",
+				Usage: "write a bbox member on each feature and on the feature collection",
+			},
+			&cli.StringSliceFlag{
+				Name:  "compute",
+				Usage: "add a property computed from an expression, e.g. \"acres=LOTSQFT / 43560\"",
 			},
 		},
 		Action: func(c *cli.Context) error {
-			idAttributeKey := strings.ToUpper(c.String("id"))
-			return Run(c.Args(), idAttributeKey)
+			opts, err := options(c)
+			if err != nil {
+				return err
+			}
+			format, err := statsFormat(c)
+			if err != nil {
+				return err
+			}
+			return Run(c.Args(), opts, c.String("report"), format)
+		},
+		Commands: []*cli.Command{
+			{
+				Name:      "validate",
+				Usage:     "check the converted geometries and print the issues found as json",
+				ArgsUsage: "[FILE]",
+				Action: func(c *cli.Context) error {
+					opts, err := options(c)
+					if err != nil {
+						return err
+					}
+					return Validate(c.Args(), opts)
+				},
+			},
+			{
+				Name:      "fetch",
+				Usage:     "query an arcgis rest layer page by page and convert its features",
+				ArgsUsage: "LAYER_URL",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "query",
+						Usage: "server side sql where `CLAUSE`",
+						Value: "1=1",
+					},
+					&cli.StringSliceFlag{
+						Name:  "out-fields",
+						Usage: "fields to fetch (default every field)",
+					},
+					&cli.StringFlag{
+						Name:  "query-bbox",
+						Usage: "only fetch features intersecting the bounding box `XMIN,YMIN,XMAX,YMAX`",
+					},
+					&cli.StringFlag{
+						Name:  "order-by",
+						Usage: "order features by `FIELDS`, e.g. \"OBJECTID ASC\"",
+					},
+					&cli.IntFlag{
+						Name:  "page-size",
+						Usage: "features asked for in each request (default the layer's maxRecordCount)",
+					},
+					&cli.StringFlag{
+						Name:  "strategy",
+						Usage: "how to page through features: offset, objectids (for servers before 10.3), extent (for layers without object id queries) or auto",
+						Value: "offset",
+					},
+					&cli.IntFlag{
+						Name:  "concurrency",
+						Usage: "batches of object ids fetched at once with the objectids strategy",
+						Value: client.DefaultConcurrency,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					opts, err := options(c)
+					if err != nil {
+						return err
+					}
+					if c.Args().Len() != 1 {
+						return fmt.Errorf("error: fetch needs a layer url")
+					}
+					q := client.Query{
+						Where:         c.String("query"),
+						OutFields:     c.StringSlice("out-fields"),
+						OrderByFields: c.String("order-by"),
+						PageSize:      c.Int("page-size"),
+						Concurrency:   c.Int("concurrency"),
+					}
+					strategy, err := client.ParseStrategy(c.String("strategy"))
+					if err != nil {
+						return err
+					}
+					q.Strategy = strategy
+					if s := c.String("query-bbox"); s != "" {
+						bbox, err := parseBBox(s)
+						if err != nil {
+							return err
+						}
+						q.Geometry = bbox
+					}
+					format, err := statsFormat(c)
+					if err != nil {
+						return err
+					}
+					return Fetch(c.Args().Get(0), q, opts, c.String("report"), format)
+				},
+			},
 		},
 	}
 
 	err := app.Run(os.Args)
+	var fe *arcgis2geojson.FeatureError
+	if errors.As(err, &fe) {
+		// name the feature that failed, then why
+		id := ""
+		if fe.ObjectID != nil {
+			id = fmt.Sprintf(" (objectid %v)", fe.ObjectID)
+		}
+		fmt.Fprintf(os.Stderr, "error: feature %d%s failed to convert\n", fe.Index, id)
+		if fe.Path != "" {
+			fmt.Fprintf(os.Stderr, "  at: %s\n", fe.Path)
+		}
+		fmt.Fprintf(os.Stderr, "  reason: %v\n", fe.Err)
+		os.Exit(1)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func Run(args cli.Args, idAttributeKey string) error {
-	var data []byte
-	var err error
+// options builds the conversion options from the command line flags
+func options(c *cli.Context) (arcgis2geojson.Options, error) {
+	opts := arcgis2geojson.Options{
+		IDAttribute:       c.String("id"),
+		IDPrefix:          c.String("id-prefix"),
+		UseAliases:        c.Bool("aliases"),
+		CoerceTypes:       c.Bool("coerce"),
+		Include:           c.StringSlice("include"),
+		Exclude:           c.StringSlice("exclude"),
+		DropSystemFields:  c.Bool("drop-system-fields"),
+		Where:             c.String("where"),
+		MakeValid:         c.Bool("make-valid"),
+		SplitAntimeridian: c.Bool("split-antimeridian"),
+		ClipProperty:      c.String("clip-property"),
+		WriteBBox:         c.Bool("write-bbox"),
+		EnvelopesAsBBox:   c.Bool("envelopes-as-bbox"),
+		SimplifyTolerance: c.Float64("simplify-tolerance"),
+		Precision:         c.Int("precision"),
+		PrecisionMeters:   c.Float64("precision-meters"),
+	}
+	collision, err := arcgis2geojson.ParseAliasCollision(c.String("alias-collision"))
+	if err != nil {
+		return opts, err
+	}
+	opts.AliasCollision = collision
+	idStrategy, err := idStrategy(c.String("id-strategy"), c.String("id"))
+	if err != nil {
+		return opts, err
+	}
+	opts.IDStrategy = idStrategy
+	idType, err := arcgis2geojson.ParseIDType(c.String("id-type"))
+	if err != nil {
+		return opts, err
+	}
+	opts.IDType = idType
+	duplicateIDs, err := arcgis2geojson.ParseDuplicateIDPolicy(c.String("duplicate-ids"))
+	if err != nil {
+		return opts, err
+	}
+	opts.DuplicateIDs = duplicateIDs
+	invalidFeatures, err := arcgis2geojson.ParseInvalidFeaturePolicy(c.String("invalid-features"))
+	if err != nil {
+		return opts, err
+	}
+	opts.InvalidFeatures = invalidFeatures
+	simplifyMethod, err := arcgis2geojson.ParseSimplifyMethod(c.String("simplify"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Simplify = simplifyMethod
+	if path := c.String("config"); path != "" {
+		mapping, err := arcgis2geojson.LoadFieldMapping(path)
+		if err != nil {
+			return opts, err
+		}
+		opts.FieldMapping = mapping
+	}
+	if s := c.String("bbox"); s != "" {
+		bbox, err := parseBBox(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.BBox = &bbox
+	}
+	if path := c.String("within"); path != "" {
+		polygons, err := arcgis2geojson.LoadPolygons(path)
+		if err != nil {
+			return opts, err
+		}
+		opts.Intersects = polygons
+	}
+	if s := c.String("clip"); s != "" {
+		bbox, err := parseBBox(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Clip = bbox
+	}
+	if path := c.String("clip-to"); path != "" {
+		if opts.Clip != nil {
+			return opts, fmt.Errorf("error: --clip and --clip-to can't be used together")
+		}
+		polygons, err := arcgis2geojson.LoadPolygons(path)
+		if err != nil {
+			return opts, err
+		}
+		opts.Clip = polygons
+	}
+	for _, s := range c.StringSlice("compute") {
+		cp, err := arcgis2geojson.ParseComputedProperty(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Compute = append(opts.Compute, cp)
+	}
+	return opts, nil
+}
+
+// idStrategy returns the id strategy for the --id-strategy and --id flags
+func idStrategy(name, id string) (arcgis2geojson.IDStrategy, error) {
+	if strings.Contains(id, "+") {
+		return arcgis2geojson.CompositeID("-", strings.Split(id, "+")...), nil
+	}
+	switch strings.ToLower(name) {
+	case "", "auto":
+		return arcgis2geojson.DefaultID(id), nil
+	case "attribute":
+		if id == "" {
+			return nil, fmt.Errorf("error: the attribute id strategy needs --id")
+		}
+		return arcgis2geojson.AttributeID(id), nil
+	case "oid":
+		return arcgis2geojson.OIDFieldID(), nil
+	case "objectidfieldname":
+		return arcgis2geojson.ObjectIDFieldNameID(), nil
+	case "hash":
+		return arcgis2geojson.HashID(), nil
+	}
+	return nil, fmt.Errorf("error: unknown id strategy %q", name)
+}
+
+// parseBBox parses a comma separated xmin,ymin,xmax,ymax bounding box
+func parseBBox(s string) (orb.Bound, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return orb.Bound{}, fmt.Errorf("error: bbox must be xmin,ymin,xmax,ymax")
+	}
+	v := make([]float64, 4)
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return orb.Bound{}, fmt.Errorf("error: invalid bbox value %q", part)
+		}
+		v[i] = f
+	}
+	if v[0] > v[2] || v[1] > v[3] {
+		return orb.Bound{}, fmt.Errorf("error: bbox min must not be greater than max, got %s", s)
+	}
+	return orb.Bound{Min: orb.Point{v[0], v[1]}, Max: orb.Point{v[2], v[3]}}, nil
+}
+
+// readInput reads the input file named by args, or stdin
+func readInput(args cli.Args) ([]byte, error) {
 	switch args.Len() {
 	case 0:
-		data, err = ioutil.ReadAll(os.Stdin)
+		return ioutil.ReadAll(os.Stdin)
+	case 1:
+		return ioutil.ReadFile(args.Get(0))
+	default:
+		fmt.Printf("input must be from stdin or file\n")
+		os.Exit(1)
+	}
+	return nil, nil
+}
+
+// openInput opens the input file named by args, or stdin, returning its size when it is known
+func openInput(args cli.Args) (io.ReadCloser, int64, error) {
+	switch args.Len() {
+	case 0:
+		return ioutil.NopCloser(os.Stdin), 0, nil
+	case 1:
+		f, err := os.Open(args.Get(0))
 		if err != nil {
-			return err
+			return nil, 0, err
 		}
-	case 1:
-		data, err = ioutil.ReadFile(args.Get(0))
+		info, err := f.Stat()
 		if err != nil {
-			return err
+			f.Close()
+			return nil, 0, err
 		}
+		return f, info.Size(), nil
 	default:
 		fmt.Printf("input must be from stdin or file\n")
 		os.Exit(1)
 	}
+	return nil, 0, nil
+}
+
+func Run(args cli.Args, opts arcgis2geojson.Options, reportPath, statsFormat string) error {
+	r, size, err := openInput(args)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
 
-	b, err := arcgis2geojson.Convert(data, idAttributeKey)
+	ctx, stop := interruptContext()
+	defer stop()
+
+	progress := newProgressBar()
+	if progress != nil {
+		opts.Progress = progress.update
+	}
+	b, report, err := arcgis2geojson.ConvertReaderContext(ctx, r, size, opts)
+	if progress != nil {
+		progress.clear()
+	}
+	if report != nil {
+		printDuplicateIDs(report)
+		printWarnings(report)
+		printRepairs(report)
+		if reportPath != "" {
+			if werr := writeReport(reportPath, report); werr != nil {
+				return werr
+			}
+		}
+	}
 	if err != nil {
 		return err
 	}
 	fmt.Println(string(b))
+	return printStats(report.Stats, statsFormat)
+}
+
+// Fetch converts the features of an arcgis rest layer matching q, streaming them to stdout
+func Fetch(layerURL string, q client.Query, opts arcgis2geojson.Options, reportPath, statsFormat string) error {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	report, err := client.New(layerURL).Convert(ctx, q, opts, os.Stdout)
+	if report != nil {
+		printDuplicateIDs(report)
+		printWarnings(report)
+		printRepairs(report)
+		if reportPath != "" {
+			if werr := writeReport(reportPath, report); werr != nil {
+				return werr
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return printStats(report.Stats, statsFormat)
+}
+
+// interruptContext returns a context cancelled on ctrl-c, and a function releasing it
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(interrupt)
+		cancel()
+	}
+}
+
+// Validate prints the geometry issues of the input as json, exiting with status 1 when there are any
+func Validate(args cli.Args, opts arcgis2geojson.Options) error {
+	data, err := readInput(args)
+	if err != nil {
+		return err
+	}
+	issues, err := arcgis2geojson.Validate(data, opts)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(struct {
+		Valid  bool                   `json:"valid"`
+		Issues []arcgis2geojson.Issue `json:"issues"`
+	}{len(issues) == 0, issues}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	if len(issues) != 0 {
+		return cli.Exit("", 1)
+	}
 	return nil
 }
+
+// writeReport writes the report of a conversion as indented json to path
+func writeReport(path string, report *arcgis2geojson.Report) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// statsFormat returns the format statistics are printed in, or "" when --stats isn't set
+func statsFormat(c *cli.Context) (string, error) {
+	if !c.Bool("stats") {
+		return "", nil
+	}
+	switch format := c.String("stats-format"); format {
+	case "text", "json":
+		return format, nil
+	default:
+		return "", fmt.Errorf("error: unknown stats format %q", format)
+	}
+}
+
+// printStats writes the statistics of a conversion to stderr as text or json, or not at all when format
+// is empty
+func printStats(stats arcgis2geojson.Stats, format string) error {
+	switch format {
+	case "":
+		return nil
+	case "json":
+		b, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, string(b))
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "features: %d in, %d out\n", stats.InputFeatures, stats.OutputFeatures)
+	fmt.Fprintf(os.Stderr, "input geometry types: %s\n", formatCounts(stats.InputGeometryTypes))
+	fmt.Fprintf(os.Stderr, "output geometry types: %s\n", formatCounts(stats.OutputGeometryTypes))
+	fmt.Fprintf(os.Stderr, "null geometries: %d\n", stats.NullGeometries)
+	fmt.Fprintf(os.Stderr, "rings dropped: %d\n", stats.RingsDropped)
+	fmt.Fprintf(os.Stderr, "holes promoted: %d\n", stats.HolesPromoted)
+	fmt.Fprintf(os.Stderr, "vertices: %d in, %d out\n", stats.InputVertices, stats.OutputVertices)
+	if stats.Extent != nil {
+		fmt.Fprintf(os.Stderr, "extent: %v\n", []float64(stats.Extent))
+	} else {
+		fmt.Fprintf(os.Stderr, "extent: none\n")
+	}
+	fmt.Fprintf(os.Stderr, "elapsed: %v\n", stats.Elapsed)
+	return nil
+}
+
+// formatCounts formats counts by name as "name n, ..." sorted by name
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	names := []string{}
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := []string{}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, counts[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printDuplicateIDs writes a summary of duplicate feature ids to stderr
+func printDuplicateIDs(report *arcgis2geojson.Report) {
+	if len(report.DuplicateIDs) == 0 {
+		return
+	}
+	features := 0
+	for _, d := range report.DuplicateIDs {
+		features += len(d.Features)
+	}
+	fmt.Fprintf(os.Stderr, "warning: %d ids shared by %d features\n", len(report.DuplicateIDs), features)
+	for _, d := range report.DuplicateIDs {
+		fmt.Fprintf(os.Stderr, "  id %v: features %v, %s\n", d.ID, d.Features, d.Resolution)
+	}
+}
+
+// printWarnings writes the warnings of a conversion to stderr
+func printWarnings(report *arcgis2geojson.Report) {
+	for _, w := range report.Warnings {
+		if w.Feature < 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w.Message)
+		} else {
+			fmt.Fprintf(os.Stderr, "warning: feature %d: %s\n", w.Feature, w.Message)
+		}
+	}
+}
+
+// printRepairs writes the geometry repairs of a conversion to stderr
+func printRepairs(report *arcgis2geojson.Report) {
+	for _, r := range report.Repairs {
+		fmt.Fprintf(os.Stderr, "repaired: feature %d: %d %s\n", r.Feature, r.Count, r.Kind)
+	}
+}
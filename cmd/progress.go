@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/engelsjk/arcgis2geojson"
+)
+
+// progressWidth is the width of the progress bar in characters
+const progressWidth = 30
+
+// progressBar draws the progress of a conversion on a terminal, at most every interval
+type progressBar struct {
+	w        io.Writer
+	interval time.Duration
+	last     time.Time
+	drawn    bool
+}
+
+// newProgressBar returns a progress bar drawn on stderr, or nil when stderr isn't a terminal
+func newProgressBar() *progressBar {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return &progressBar{w: os.Stderr, interval: 100 * time.Millisecond}
+}
+
+// update redraws the bar, while reading the input and then while converting its features
+func (b *progressBar) update(p arcgis2geojson.Progress) {
+	done := p.TotalFeatures != 0 && p.Features == p.TotalFeatures
+	if now := time.Now(); done || now.Sub(b.last) >= b.interval {
+		b.last = now
+	} else {
+		return
+	}
+	var line string
+	switch {
+	case p.TotalFeatures != 0:
+		line = fmt.Sprintf("converting %s %d/%d features", bar(float64(p.Features)/float64(p.TotalFeatures)), p.Features, p.TotalFeatures)
+	case p.TotalBytes != 0:
+		line = fmt.Sprintf("reading    %s %s/%s", bar(float64(p.BytesRead)/float64(p.TotalBytes)), formatBytes(p.BytesRead), formatBytes(p.TotalBytes))
+	default:
+		line = fmt.Sprintf("reading    %s", formatBytes(p.BytesRead))
+	}
+	// \033[K clears what is left of a longer previous line
+	fmt.Fprintf(b.w, "\r%s\033[K", line)
+	b.drawn = true
+}
+
+// clear removes the bar, so warnings and errors start on an empty line
+func (b *progressBar) clear() {
+	if b.drawn {
+		fmt.Fprint(b.w, "\r\033[K")
+		b.drawn = false
+	}
+}
+
+// bar draws a fraction between 0 and 1 as a bar with a percentage
+func bar(fraction float64) string {
+	if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction * progressWidth)
+	return fmt.Sprintf("[%s%s] %3.0f%%", strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled), fraction*100)
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,148 @@
+package arcgis2geojson
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// esri field types
+const (
+	FieldTypeSmallInteger = "esriFieldTypeSmallInteger"
+	FieldTypeInteger      = "esriFieldTypeInteger"
+	FieldTypeBigInteger   = "esriFieldTypeBigInteger"
+	FieldTypeSingle       = "esriFieldTypeSingle"
+	FieldTypeDouble       = "esriFieldTypeDouble"
+	FieldTypeString       = "esriFieldTypeString"
+	FieldTypeDate         = "esriFieldTypeDate"
+	FieldTypeOID          = "esriFieldTypeOID"
+	FieldTypeGeometry     = "esriFieldTypeGeometry"
+	FieldTypeBlob         = "esriFieldTypeBlob"
+	FieldTypeRaster       = "esriFieldTypeRaster"
+	FieldTypeGUID         = "esriFieldTypeGUID"
+	FieldTypeGlobalID     = "esriFieldTypeGlobalID"
+	FieldTypeXML          = "esriFieldTypeXML"
+)
+
+// coerce converts an attribute value to the go type matching an esri field type.
+// values that can't be converted are returned unchanged.
+func coerce(v interface{}, fieldType string) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch fieldType {
+	case FieldTypeSmallInteger, FieldTypeInteger, FieldTypeBigInteger, FieldTypeOID, FieldTypeDate:
+		if i, ok := toInt64(v); ok {
+			return i
+		}
+	case FieldTypeSingle, FieldTypeDouble:
+		if f, ok := toFloat64(v); ok {
+			return f
+		}
+	case FieldTypeGUID, FieldTypeGlobalID:
+		if s, ok := v.(string); ok {
+			if guid, ok := normalizeGUID(s); ok {
+				return guid
+			}
+		}
+	case FieldTypeBlob:
+		return toBase64(v)
+	case FieldTypeString, FieldTypeXML:
+		return toString(v)
+	}
+	return v
+}
+
+// toInt64 converts json numbers, floats with no fractional part and numeric strings to int64
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		return toInt64(string(n))
+	case string:
+		s := strings.TrimSpace(n)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return toInt64(f)
+		}
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) < 1<<63 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+// toFloat64 converts json numbers, integers and numeric strings to float64
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v interface{}) interface{} {
+	switch s := v.(type) {
+	case string:
+		return s
+	case json.Number:
+		return s.String()
+	case bool, float64, int64:
+		return fmt.Sprint(s)
+	}
+	return v
+}
+
+// normalizeGUID formats a guid the way esri writes it, upper case and wrapped in braces
+func normalizeGUID(s string) (string, bool) {
+	hex := strings.ToUpper(strings.Trim(strings.TrimSpace(s), "{}"))
+	hex = strings.Replace(hex, "-", "", -1)
+	if len(hex) != 32 {
+		return s, false
+	}
+	for _, r := range hex {
+		if !(r >= '0' && r <= '9' || r >= 'A' && r <= 'F') {
+			return s, false
+		}
+	}
+	return fmt.Sprintf("{%s-%s-%s-%s-%s}", hex[0:8], hex[8:12], hex[12:16], hex[16:20], hex[20:32]), true
+}
+
+// toBase64 encodes blobs given as byte arrays as base64. blobs that are already strings are kept.
+func toBase64(v interface{}) interface{} {
+	values, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	b := make([]byte, len(values))
+	for i, value := range values {
+		n, ok := toInt64(value)
+		if !ok || n < 0 || n > 255 {
+			return v
+		}
+		b[i] = byte(n)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,49 @@
+package arcgis2geojson
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		value     interface{}
+		fieldType string
+		expected  interface{}
+	}{
+		{json.Number("9007199254740993"), FieldTypeOID, int64(9007199254740993)},
+		{json.Number("316"), FieldTypeSmallInteger, int64(316)},
+		{json.Number("2.0"), FieldTypeInteger, int64(2)},
+		{"42", FieldTypeBigInteger, int64(42)},
+		{json.Number("2.32"), FieldTypeDouble, 2.32},
+		{json.Number("98057"), FieldTypeString, "98057"},
+		{"a0b1c2d3-e4f5-a6b7-c8d9-e0f1a2b3c4d5", FieldTypeGlobalID, "{A0B1C2D3-E4F5-A6B7-C8D9-E0F1A2B3C4D5}"},
+		{"not a guid", FieldTypeGUID, "not a guid"},
+		{[]interface{}{json.Number("104"), json.Number("105")}, FieldTypeBlob, "aGk="},
+		{"aGk=", FieldTypeBlob, "aGk="},
+		{nil, FieldTypeInteger, nil},
+		{"n/a", FieldTypeInteger, "n/a"},
+	}
+	for _, test := range tests {
+		v := coerce(test.value, test.fieldType)
+		if v != test.expected {
+			t.Errorf("coerce(%v, %s): expected %#v, got %#v", test.value, test.fieldType, test.expected, v)
+		}
+	}
+}
+
+func TestCoercePreservesPrecision(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"fields": [{"name": "OBJECTID", "type": "esriFieldTypeOID"}],
+		"features": [{"attributes": {"OBJECTID": 9007199254740993}, "geometry": {"points": [[1, 2]]}}]
+	}`
+	b, err := ConvertWithOptions([]byte(data), Options{CoerceTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"OBJECTID":9007199254740993`) {
+		t.Errorf("expected lossless object id, got %s", b)
+	}
+}
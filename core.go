@@ -1,34 +1,188 @@
 package arcgis2geojson
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/paulmach/orb"
 	geojson "github.com/paulmach/orb/geojson"
 )
 
+// Convert converts arcgis json to geojson, using idAttribute as the feature id
 func Convert(data []byte, idAttribute string) ([]byte, error) {
-	arcgisJSON := ArcGISJSON{}
-	err := json.Unmarshal(data, &arcgisJSON)
+	return ConvertWithOptions(data, Options{IDAttribute: idAttribute})
+}
+
+// ConvertWithOptions converts arcgis json to geojson as configured by opts
+func ConvertWithOptions(data []byte, opts Options) ([]byte, error) {
+	b, _, err := ConvertWithReport(data, opts)
+	return b, err
+}
+
+// ConvertWithReport converts arcgis json to geojson as configured by opts, and reports on the conversion
+func ConvertWithReport(data []byte, opts Options) ([]byte, *Report, error) {
+	return ConvertContext(context.Background(), data, opts)
+}
+
+// convert converts arcgis json to geojson, checking ctx and reporting progress between features.
+// progress holds the bytes read.
+func convert(ctx context.Context, data []byte, opts Options, progress Progress) ([]byte, *Report, error) {
+	start := time.Now()
+	c, features, indexes, err := convertFeatures(ctx, data, opts, progress)
+	if c == nil {
+		return nil, nil, err
+	}
+	if err != nil {
+		return nil, c.report, err
+	}
+	fc := geojson.NewFeatureCollection()
+	fc.Features, err = c.resolveDuplicateIDs(features, indexes)
+	if err != nil {
+		return nil, c.report, err
+	}
+	if opts.WriteBBox {
+		unchanged := !c.invalid && opts.Clip == nil && len(fc.Features) == len(c.arcgisJSON.Features)
+		c.collectionBBox(fc, unchanged)
+	}
+	c.outputStats(fc.Features)
+	b, err := fc.MarshalJSON()
+	c.report.Stats.Elapsed = time.Since(start)
+	return b, c.report, err
+}
+
+// convertFeatures converts the features of arcgis json, returning them with the input index of each.
+// the converter is nil when the input can't be decoded or the options are invalid.
+func convertFeatures(ctx context.Context, data []byte, opts Options, progress Progress) (*converter, []*geojson.Feature, []int, error) {
+	arcgisJSON, err := decode(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	c, err := newConverter(arcgisJSON, opts)
 	if err != nil {
+		return nil, nil, nil, err
+	}
+	c.report.Stats.InputFeatures = len(arcgisJSON.Features)
+	c.report.Stats.InputGeometryTypes = map[string]int{}
+	features := []*geojson.Feature{}
+	// input index of each output feature
+	indexes := []int{}
+	progress.TotalFeatures = len(arcgisJSON.Features)
+	for i := 0; i < len(arcgisJSON.Features); i++ {
+		if opts.Progress != nil {
+			progress.Features = i
+			opts.Progress(progress)
+		}
+		if err := ctx.Err(); err != nil {
+			return c, nil, nil, fmt.Errorf("error: conversion cancelled after %d features: %w", i, err)
+		}
+		f := arcgisJSON.Features[i]
+		if !c.keepFeature(f) {
+			continue
+		}
+		if keys := f.Geometry.unknownKeys; len(keys) != 0 {
+			c.warn(i, "ignored unsupported geometry keys %s", strings.Join(keys, ", "))
+		}
+		if path, err := checkGeometry(f); err != nil {
+			fe := c.featureError(f, i, path, err)
+			if opts.InvalidFeatures == InvalidFeaturesError {
+				return c, nil, nil, fe
+			}
+			c.invalid = true
+			var ok bool
+			if f, ok = c.invalidFeature(f, fe); !ok {
+				continue
+			}
+		}
+		// ring stats only count features that pass the spatial filters
+		ringsDropped, holesPromoted := c.report.Stats.RingsDropped, c.report.Stats.HolesPromoted
+		feature := c.featureToFeature(f, i)
+		if feature == nil || !c.keepGeometry(feature) {
+			c.report.Stats.RingsDropped, c.report.Stats.HolesPromoted = ringsDropped, holesPromoted
+			continue
+		}
+		c.inputStats(arcgisJSON.Features[i])
+		if opts.WriteBBox {
+			c.featureBBox(feature, f, i)
+		}
+		features = append(features, feature)
+		indexes = append(indexes, i)
+	}
+	if opts.Progress != nil {
+		progress.Features = len(arcgisJSON.Features)
+		opts.Progress(progress)
+	}
+	return c, features, indexes, nil
+}
+
+// decode decodes arcgis json, checking it is in a spatial reference geojson can hold
+func decode(data []byte) (*ArcGISJSON, error) {
+	arcgisJSON := &ArcGISJSON{}
+	// decode attribute numbers as json.Number so no precision is lost
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(arcgisJSON); err != nil {
 		return nil, err
 	}
 	if arcgisJSON.SpatialReference.WKID != 4326 {
-		return nil, errors.New("error: arc gis features must be in wkid 4326 for valid conversion to geojson")
+		return nil, fmt.Errorf("%w, got wkid %d", ErrUnsupportedSpatialReference, arcgisJSON.SpatialReference.WKID)
 	}
-	fc := geojson.NewFeatureCollection()
-	if len(arcgisJSON.Features) != 0 {
-		for i := 0; i < len(arcgisJSON.Features); i++ {
-			f := arcgisJSON.Features[i]
-			feature := featureToFeature(f, idAttribute)
-			fc.Features = append(fc.Features, feature)
+	return arcgisJSON, nil
+}
+
+// checkGeometry checks that the coordinates of a feature can be converted, returning the json path of
+// the first malformed coordinate array
+func checkGeometry(f ArcGISFeature) (string, error) {
+	checkPoints := func(path string, points [][]float64) (string, error) {
+		for i, pt := range points {
+			if len(pt) < 2 {
+				return fmt.Sprintf("%s[%d]", path, i), fmt.Errorf("point has %d values, expected at least 2", len(pt))
+			}
+		}
+		return "", nil
+	}
+	for _, points := range []struct {
+		path   string
+		points [][]float64
+	}{{"points", f.Points}, {"geometry.points", f.Geometry.Points}} {
+		if path, err := checkPoints(points.path, points.points); err != nil {
+			return path, err
 		}
 	}
-	return fc.MarshalJSON()
+	for _, paths := range []struct {
+		path  string
+		paths [][][]float64
+	}{{"paths", f.Paths}, {"geometry.paths", f.Geometry.Paths}} {
+		for i, points := range paths.paths {
+			path := fmt.Sprintf("%s[%d]", paths.path, i)
+			if len(points) == 0 {
+				return path, errors.New("path is empty")
+			}
+			if path, err := checkPoints(path, points); err != nil {
+				return path, err
+			}
+		}
+	}
+	// empty and degenerate rings are dropped by convertRingsToGeoJSON
+	for _, rings := range []struct {
+		path  string
+		rings []Ring
+	}{{"rings", f.Rings}, {"geometry.rings", f.Geometry.Rings}} {
+		for i, ring := range rings.rings {
+			if path, err := checkPoints(fmt.Sprintf("%s[%d]", rings.path, i), ring); err != nil {
+				return path, err
+			}
+		}
+	}
+	return "", nil
 }
 
-func featureToFeature(f ArcGISFeature, idAttribute string) *geojson.Feature {
+// featureToFeature converts a feature, returning nil when clipping leaves nothing of it
+func (c *converter) featureToFeature(f ArcGISFeature, index int) *geojson.Feature {
 
 	var feature = new(geojson.Feature)
 
@@ -59,25 +213,48 @@ func featureToFeature(f ArcGISFeature, idAttribute string) *geojson.Feature {
 
 	// rings >> polygon/multipolygon
 	if len(f.Rings) != 0 {
-		feature = ringsToFeature(f.Rings)
+		feature = c.ringsToFeature(f.Rings, "rings", index)
 	}
 	if len(f.Geometry.Rings) != 0 {
-		feature = ringsToFeature(f.Geometry.Rings)
+		feature = c.ringsToFeature(f.Geometry.Rings, "geometry.rings", index)
+	}
+
+	// xmin/xmax/ymin/ymax >> bounding box (polygon, or bbox member with a null geometry)
+	if bbox, ok := featureEnvelope(f); ok {
+		if c.opts.EnvelopesAsBBox {
+			feature = geojson.NewFeature(nil)
+			feature.BBox = geojson.BBox(bbox)
+		} else {
+			feature = boundingBoxToFeature(bbox)
+		}
 	}
 
-	// xmin/xmax/ymin/ymax >> bounding box (polygon)
-	bbox := []float64{f.Xmin, f.Ymin, f.Xmax, f.Ymax}
-	if bbox[0] != 0 && bbox[1] != 0 && bbox[2] != 0 && bbox[3] != 0 {
-		feature = boundingBoxToFeature(bbox)
+	// repair, split, clip, simplify, round, ...
+	if feature.Geometry != nil && c.opts.MakeValid {
+		feature.Geometry = c.makeValid(feature.Geometry, index)
+	}
+	if feature.Geometry != nil && c.opts.SplitAntimeridian {
+		feature.Geometry = SplitAntimeridian(feature.Geometry)
+	}
+	clipped := false
+	if c.opts.Clip != nil {
+		var kept bool
+		if kept, clipped = c.clipFeature(feature); !kept {
+			return nil
+		}
+	}
+	if feature.Geometry != nil {
+		feature.Geometry = c.transformGeometry(feature.Geometry, index)
 	}
 
 	// add properties
-	feature.Properties = make(map[string]interface{})
-	for k, v := range f.Attributes {
-		feature.Properties[k] = v
+	feature.Properties = c.properties(f.Attributes)
+	c.computeProperties(feature.Properties, f.Attributes, feature.Geometry)
+	if clipped {
+		feature.Properties[c.clipProperty()] = true
 	}
 	// add id
-	id, err := getId(f.Attributes, idAttribute)
+	id, err := c.featureID(f)
 	if err == nil {
 		feature.ID = id
 	}
@@ -89,38 +266,54 @@ func featureToFeature(f ArcGISFeature, idAttribute string) *geojson.Feature {
 
 type ArcGISFeature struct {
 	Attributes map[string]interface{} `json:"attributes"`
-	Geometry   struct {
-		Points [][]float64   `json:"points"`
-		Paths  [][][]float64 `json:"paths"`
-		Rings  []Ring        `json:"rings"`
-	} `json:"geometry"`
-	X      float64       `json:"x"`
-	Y      float64       `json:"y"`
-	Z      float64       `json:"z"`
-	Xmin   float64       `json:"xmin"`
-	Xmax   float64       `json:"xmax"`
-	Ymin   float64       `json:"ymin"`
-	Ymax   float64       `json:"ymax"`
-	Paths  [][][]float64 `json:"paths"`
+	Geometry   ArcGISGeometry         `json:"geometry"`
+	X          float64                `json:"x"`
+	Y          float64                `json:"y"`
+	Z          float64                `json:"z"`
+	Xmin       *float64               `json:"xmin"`
+	Xmax       *float64               `json:"xmax"`
+	Ymin       *float64               `json:"ymin"`
+	Ymax       *float64               `json:"ymax"`
+	Paths      [][][]float64          `json:"paths"`
+	Points     [][]float64            `json:"points"`
+	Rings      []Ring                 `json:"rings"`
+}
+
+type ArcGISGeometry struct {
 	Points [][]float64   `json:"points"`
+	Paths  [][][]float64 `json:"paths"`
 	Rings  []Ring        `json:"rings"`
+
+	// members the conversion ignores, like curveRings, in sorted order
+	unknownKeys []string
 }
 
 type ArcGISJSON struct {
-	DisplayFieldName string            `json:"displayFieldName"`
-	FieldAliases     map[string]string `json:"fieldAliases"`
-	GeometryType     string
-	SpatialReference struct {
+	DisplayFieldName  string            `json:"displayFieldName"`
+	ObjectIDFieldName string            `json:"objectIdFieldName"`
+	FieldAliases      map[string]string `json:"fieldAliases"`
+	GeometryType      string
+	SpatialReference  struct {
 		WKID       int
 		LatestWKID int
 	} `json:"spatialReference"`
-	Fields []struct {
-		Name   string `json:"name"`
-		Type   string `json:"type"`
-		Alias  string `json:"alias"`
-		Length int    `json:"length"`
-	} `json:"fields"`
+	Fields   []Field         `json:"fields"`
 	Features []ArcGISFeature `json:"features"`
+	Extent   *Envelope       `json:"extent"`
+}
+
+type Envelope struct {
+	Xmin float64 `json:"xmin"`
+	Ymin float64 `json:"ymin"`
+	Xmax float64 `json:"xmax"`
+	Ymax float64 `json:"ymax"`
+}
+
+type Field struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Alias  string `json:"alias"`
+	Length int    `json:"length"`
 }
 
 // feature conversions
@@ -168,12 +361,24 @@ func pathsToFeature(paths [][][]float64) *geojson.Feature {
 	return feature
 }
 
-func ringsToFeature(rings []Ring) *geojson.Feature {
+// ringsToFeature converts the rings at path of a feature, warning about rings dropped or moved
+func (c *converter) ringsToFeature(rings []Ring, path string, index int) *geojson.Feature {
 	var feature = new(geojson.Feature)
 	if len(rings) == 0 {
 		return feature
 	}
-	polygons := convertRingsToGeoJSON(rings)
+	polygons, notes := convertRings(rings)
+	c.report.Stats.RingsDropped += len(notes.dropped)
+	c.report.Stats.HolesPromoted += len(notes.promoted)
+	for _, r := range notes.dropped {
+		c.warn(index, "%s[%d]: dropped degenerate ring", path, r)
+	}
+	for _, r := range notes.crossing {
+		c.warn(index, "%s[%d]: hole outside every outer ring added to an outer ring it crosses", path, r)
+	}
+	for _, r := range notes.promoted {
+		c.warn(index, "%s[%d]: hole outside every outer ring became an outer ring", path, r)
+	}
 	newPolygons := []orb.Polygon{}
 	for _, p := range polygons {
 		polygon := orb.Polygon{}
@@ -186,7 +391,10 @@ func ringsToFeature(rings []Ring) *geojson.Feature {
 		}
 		newPolygons = append(newPolygons, polygon)
 	}
-	// TODO: if len(outerRings) == 0?
+	if len(newPolygons) == 0 {
+		c.warn(index, "%s: every ring was dropped, geometry set to null", path)
+		return geojson.NewFeature(nil)
+	}
 	if len(newPolygons) == 1 {
 		p := newPolygons[0]
 		feature = geojson.NewFeature(p)
@@ -197,13 +405,22 @@ func ringsToFeature(rings []Ring) *geojson.Feature {
 	return feature
 }
 
+// envelopes crossing the antimeridian (xmin > xmax) become a multipolygon split at 180°
 func boundingBoxToFeature(bbox []float64) *geojson.Feature {
+	if bbox[0] > bbox[2] {
+		east := boundingBoxToPolygon([]float64{bbox[0], bbox[1], 180, bbox[3]})
+		west := boundingBoxToPolygon([]float64{-180, bbox[1], bbox[2], bbox[3]})
+		return geojson.NewFeature(orb.MultiPolygon{east, west})
+	}
+	return geojson.NewFeature(boundingBoxToPolygon(bbox))
+}
+
+func boundingBoxToPolygon(bbox []float64) orb.Polygon {
 	ring := orb.Ring{}
 	ring = append(ring, orb.Point{bbox[2], bbox[3]})
 	ring = append(ring, orb.Point{bbox[0], bbox[3]})
 	ring = append(ring, orb.Point{bbox[0], bbox[1]})
 	ring = append(ring, orb.Point{bbox[2], bbox[1]})
 	ring = append(ring, orb.Point{bbox[2], bbox[3]})
-	p := orb.Polygon{ring}
-	return geojson.NewFeature(p)
+	return orb.Polygon{ring}
 }
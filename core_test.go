@@ -1,6 +1,12 @@
 package arcgis2geojson
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/paulmach/orb"
+	geojson "github.com/paulmach/orb/geojson"
+)
 
 func TestConversion1(t *testing.T) {
 	data := []byte(`{
@@ -284,3 +290,91 @@ func TestConversion2(t *testing.T) {
 	}
 	t.Log(string(b))
 }
+
+// convertFeatureCollection converts data with opts and decodes the result
+func convertFeatureCollection(t *testing.T, data string, opts Options) *geojson.FeatureCollection {
+	t.Helper()
+	b, err := ConvertWithOptions([]byte(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fc
+}
+
+// rawFeatureCollection is a decoded feature collection that keeps geometries as raw json, since
+// orb can't decode features with a null geometry
+type rawFeatureCollection struct {
+	BBox     []float64 `json:"bbox"`
+	Features []struct {
+		ID         interface{}            `json:"id"`
+		BBox       []float64              `json:"bbox"`
+		Geometry   json.RawMessage        `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	} `json:"features"`
+}
+
+// convertRawFeatureCollection converts data with opts and decodes the result, keeping geometries raw
+func convertRawFeatureCollection(t *testing.T, data string, opts Options) *rawFeatureCollection {
+	t.Helper()
+	b, err := ConvertWithOptions([]byte(data), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := &rawFeatureCollection{}
+	if err := json.Unmarshal(b, fc); err != nil {
+		t.Fatal(err)
+	}
+	return fc
+}
+
+func TestConvertHoles(t *testing.T) {
+	holes := map[string]string{
+		"contained": `[[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]`,
+		"crossing":  `[[8, 4], [12, 4], [12, 6], [8, 6], [8, 4]]`,
+	}
+	for name, hole := range holes {
+		data := `{
+			"spatialReference": {"wkid": 4326},
+			"features": [
+				{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [
+					[[0, 0], [0, 10], [10, 10], [10, 0], [0, 0]],
+					` + hole + `
+				]}}
+			]
+		}`
+		fc := convertFeatureCollection(t, data, Options{})
+		p, ok := fc.Features[0].Geometry.(orb.Polygon)
+		if !ok || len(p) != 2 {
+			t.Errorf("%s: expected a polygon with a hole, got %v", name, fc.Features[0].Geometry)
+		}
+	}
+}
+
+func TestConvertMalformed(t *testing.T) {
+	tests := []struct {
+		geometry string
+		expected string
+	}{
+		{`"geometry": {"points": [[1]]}`, "error: feature 0 (objectid 1): geometry.points[0]: point has 1 values, expected at least 2"},
+		{`"geometry": {"paths": [[]]}`, "error: feature 0 (objectid 1): geometry.paths[0]: path is empty"},
+		{`"paths": [[[0, 0], []]]`, "error: feature 0 (objectid 1): paths[0][1]: point has 0 values, expected at least 2"},
+		{`"geometry": {"rings": [[[0, 0], [1], [1, 1], [0, 0]]]}`, "error: feature 0 (objectid 1): geometry.rings[0][1]: point has 1 values, expected at least 2"},
+	}
+	for _, test := range tests {
+		data := `{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, ` + test.geometry + `}]}`
+		_, err := Convert([]byte(data), "")
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: expected %q, got %v", test.geometry, test.expected, err)
+		}
+	}
+
+	// empty rings are dropped like other degenerate rings
+	data := `{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [[]]}}]}`
+	if _, err := Convert([]byte(data), ""); err != nil {
+		t.Errorf("expected empty rings to be dropped, got %v", err)
+	}
+}
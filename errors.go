@@ -0,0 +1,56 @@
+package arcgis2geojson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedSpatialReference is returned for input that isn't in wkid 4326
+var ErrUnsupportedSpatialReference = errors.New("error: arc gis features must be in wkid 4326 for valid conversion to geojson")
+
+// FeatureError is a failure to convert one feature of the input
+type FeatureError struct {
+	// Index is the index of the feature in the input
+	Index int
+	// ObjectID is the object id of the feature, or nil when it has none
+	ObjectID interface{}
+	// Path is the json path of the problem within the feature, e.g. geometry.paths[0], when it is known
+	Path string
+	Err  error
+}
+
+func (e *FeatureError) Error() string {
+	s := fmt.Sprintf("error: feature %d", e.Index)
+	if e.ObjectID != nil {
+		s += fmt.Sprintf(" (objectid %v)", e.ObjectID)
+	}
+	if e.Path != "" {
+		s += ": " + e.Path
+	}
+	return s + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FeatureError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateIDError is returned when features share an id and duplicate ids are an error
+type DuplicateIDError struct {
+	ID interface{}
+	// Features are the indexes in the input of the features sharing the id
+	Features []int
+}
+
+func (e *DuplicateIDError) Error() string {
+	return fmt.Sprintf("error: duplicate id %v in features %v", e.ID, e.Features)
+}
+
+// objectIDStrategy finds the object id reported in feature errors
+var objectIDStrategy = FirstID(OIDFieldID(), ObjectIDFieldNameID(), AttributeID("OBJECTID"), AttributeID("FID"))
+
+// featureError wraps err as the failure of the feature at index
+func (c *converter) featureError(f ArcGISFeature, index int, path string, err error) *FeatureError {
+	objectID, _ := objectIDStrategy.ID(f, c.arcgisJSON)
+	return &FeatureError{Index: index, ObjectID: objectID, Path: path, Err: err}
+}
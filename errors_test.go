@@ -0,0 +1,59 @@
+package arcgis2geojson
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFeatureError(t *testing.T) {
+	data := `{"spatialReference": {"wkid": 4326}, "features": [
+		{"attributes": {"OBJECTID": 1}, "geometry": {"x": 1, "y": 2}},
+		{"attributes": {"OBJECTID": 17}, "geometry": {"paths": [[]]}}
+	]}`
+	_, err := Convert([]byte(data), "")
+	var fe *FeatureError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected a feature error, got %v", err)
+	}
+	if fe.Index != 1 || fe.Path != "geometry.paths[0]" || fe.Err == nil {
+		t.Errorf("expected feature 1 at geometry.paths[0], got %+v", fe)
+	}
+	if fmt.Sprint(fe.ObjectID) != "17" {
+		t.Errorf("expected objectid 17, got %v", fe.ObjectID)
+	}
+	if !errors.Is(err, fe.Err) {
+		t.Errorf("expected the error to wrap %v", fe.Err)
+	}
+
+	// without an object id
+	data = `{"spatialReference": {"wkid": 4326}, "features": [{"geometry": {"paths": [[]]}}]}`
+	_, err = Convert([]byte(data), "")
+	if expected := "error: feature 0: geometry.paths[0]: path is empty"; err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestUnsupportedSpatialReference(t *testing.T) {
+	data := `{"spatialReference": {"wkid": 3857}, "features": []}`
+	_, err := Convert([]byte(data), "")
+	if !errors.Is(err, ErrUnsupportedSpatialReference) {
+		t.Errorf("expected ErrUnsupportedSpatialReference, got %v", err)
+	}
+}
+
+func TestDuplicateIDError(t *testing.T) {
+	data := `{"spatialReference": {"wkid": 4326}, "features": [
+		{"attributes": {"OBJECTID": 1}, "geometry": {"x": 1, "y": 2}},
+		{"attributes": {"OBJECTID": 1}, "geometry": {"x": 3, "y": 4}}
+	]}`
+	_, err := ConvertWithOptions([]byte(data), Options{IDAttribute: "OBJECTID", DuplicateIDs: DuplicateIDsError})
+	var de *DuplicateIDError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a duplicate id error, got %v", err)
+	}
+	if !reflect.DeepEqual(de.Features, []int{0, 1}) {
+		t.Errorf("expected features [0 1], got %v", de.Features)
+	}
+}
@@ -0,0 +1,189 @@
+package arcgis2geojson
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/planar"
+)
+
+// record is what where clauses and expressions are evaluated against
+type record struct {
+	attributes map[string]interface{}
+	geometry   orb.Geometry
+}
+
+// Expression is a parsed value expression over a feature's attributes and geometry, using the
+// operand syntax of where clauses, e.g. ADDR_HN + ' ' + STREET, LOTSQFT / 43560 or ROUND(AREA(), 2)
+type Expression struct {
+	text string
+	root valueNode
+}
+
+// ParseExpression parses a value expression
+func ParseExpression(s string) (*Expression, error) {
+	tokens, err := lexWhere(s)
+	if err != nil {
+		return nil, fmt.Errorf("error: invalid expression: %v", err)
+	}
+	p := &whereParser{tokens: tokens, geometry: true}
+	root, err := p.parseValue()
+	if err == nil {
+		err = p.expectEOF()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error: invalid expression: %v", err)
+	}
+	return &Expression{text: s, root: root}, nil
+}
+
+// String returns the expression as it was parsed
+func (e *Expression) String() string {
+	return e.text
+}
+
+// Evaluate evaluates the expression. the result is nil, an int64, a float64, a string or a bool. like
+// sql, results that are NaN or infinite are null.
+func (e *Expression) Evaluate(attributes map[string]interface{}, g orb.Geometry) interface{} {
+	return e.root.value(&record{attributes: attributes, geometry: g})
+}
+
+// ComputedProperty is a property derived from an expression during conversion
+type ComputedProperty struct {
+	Name       string
+	Expression *Expression
+}
+
+// ParseComputedProperty parses a name=expression definition
+func ParseComputedProperty(s string) (ComputedProperty, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return ComputedProperty{}, fmt.Errorf("error: computed property must be name=expression, got %q", s)
+	}
+	e, err := ParseExpression(s[i+1:])
+	if err != nil {
+		return ComputedProperty{}, err
+	}
+	return ComputedProperty{Name: strings.TrimSpace(s[:i]), Expression: e}, nil
+}
+
+// computeProperties adds computed properties to props. each expression sees the feature's attributes
+// and the properties computed before it.
+func (c *converter) computeProperties(props, attributes map[string]interface{}, g orb.Geometry) {
+	if len(c.opts.Compute) == 0 {
+		return
+	}
+	r := &record{attributes: make(map[string]interface{}, len(attributes)), geometry: g}
+	for k, v := range attributes {
+		r.attributes[k] = v
+	}
+	for _, cp := range c.opts.Compute {
+		v := cp.Expression.root.value(r)
+		props[cp.Name] = v
+		r.attributes[cp.Name] = v
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////////////////
+// functions
+
+type expressionFunction struct {
+	minArgs, maxArgs int // maxArgs < 0 means any number of arguments
+	call             func(r *record, args []interface{}) interface{}
+}
+
+var expressionFunctions map[string]expressionFunction
+
+func init() {
+	expressionFunctions = map[string]expressionFunction{
+		"UPPER": {1, 1, stringFunction(strings.ToUpper)},
+		"LOWER": {1, 1, stringFunction(strings.ToLower)},
+		"TRIM":  {1, 1, stringFunction(strings.TrimSpace)},
+		"CONCAT": {1, -1, func(r *record, args []interface{}) interface{} {
+			var sb strings.Builder
+			for _, arg := range args {
+				if arg != nil {
+					sb.WriteString(whereString(arg))
+				}
+			}
+			return sb.String()
+		}},
+		"COALESCE": {1, -1, func(r *record, args []interface{}) interface{} {
+			for _, arg := range args {
+				if arg != nil {
+					return arg
+				}
+			}
+			return nil
+		}},
+		"ABS": {1, 1, func(r *record, args []interface{}) interface{} {
+			if f, ok := toFloat64(args[0]); ok {
+				return math.Abs(f)
+			}
+			return nil
+		}},
+		"ROUND": {1, 2, func(r *record, args []interface{}) interface{} {
+			f, ok := toFloat64(args[0])
+			if !ok {
+				return nil
+			}
+			digits := 0.0
+			if len(args) == 2 {
+				if digits, ok = toFloat64(args[1]); !ok {
+					return nil
+				}
+			}
+			pow := math.Pow(10, math.Trunc(digits))
+			switch {
+			case math.IsInf(pow, 0) || math.IsInf(f*pow, 0):
+				// more digits than a float64 holds
+				return f
+			case pow == 0:
+				return 0.0
+			}
+			return math.Round(f*pow) / pow
+		}},
+		// geodesic area in square meters
+		"AREA": {0, 0, geometryFunction(func(g orb.Geometry) interface{} {
+			return geo.Area(g)
+		})},
+		// geodesic length, or perimeter of polygons, in meters
+		"LENGTH": {0, 0, geometryFunction(func(g orb.Geometry) interface{} {
+			return geo.Length(g)
+		})},
+		"CENTROID_X": {0, 0, geometryFunction(func(g orb.Geometry) interface{} {
+			c, _ := planar.CentroidArea(g)
+			return c[0]
+		})},
+		"CENTROID_Y": {0, 0, geometryFunction(func(g orb.Geometry) interface{} {
+			c, _ := planar.CentroidArea(g)
+			return c[1]
+		})},
+	}
+}
+
+// geometryFunctions are the functions of the record geometry
+var geometryFunctions = map[string]bool{"AREA": true, "LENGTH": true, "CENTROID_X": true, "CENTROID_Y": true}
+
+// stringFunction wraps a string function, returning null for null input
+func stringFunction(f func(string) string) func(r *record, args []interface{}) interface{} {
+	return func(r *record, args []interface{}) interface{} {
+		if args[0] == nil {
+			return nil
+		}
+		return f(whereString(args[0]))
+	}
+}
+
+// geometryFunction wraps a measure of the record geometry, returning null when there is no geometry
+func geometryFunction(f func(g orb.Geometry) interface{}) func(r *record, args []interface{}) interface{} {
+	return func(r *record, args []interface{}) interface{} {
+		if r.geometry == nil {
+			return nil
+		}
+		return f(r.geometry)
+	}
+}
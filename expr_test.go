@@ -0,0 +1,102 @@
+package arcgis2geojson
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestExpression(t *testing.T) {
+	attributes := map[string]interface{}{
+		"ADDR_HN": "737",
+		"STREET":  "LOGAN AVE N",
+		"LOTSQFT": json.Number("87120"),
+		"MISSING": nil,
+	}
+	square := orb.Polygon{{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}}}
+	tests := []struct {
+		expression string
+		expected   interface{}
+	}{
+		{"ADDR_HN + ' ' + STREET", "737 LOGAN AVE N"},
+		{"ADDR_HN || '-' || LOTSQFT", "737-87120"},
+		{"LOTSQFT / 43560", 2.0},
+		{"(LOTSQFT - 120) * 2", 174000.0},
+		{"ADDR_HN + MISSING", nil},
+		{"CONCAT(ADDR_HN, MISSING, ' ', lower(STREET))", "737 logan ave n"},
+		{"COALESCE(MISSING, STREET)", "LOGAN AVE N"},
+		{"ROUND(LOTSQFT / 7, 2)", 12445.71},
+		{"CENTROID_X() + CENTROID_Y()", 2.0},
+		// non-finite results are null, like sql
+		{"LOTSQFT / 0", nil},
+		{"LOTSQFT * 1e308 * 10", nil},
+		{"ROUND(LOTSQFT, 400)", 87120.0},
+		{"ROUND(LOTSQFT, -400)", 0.0},
+	}
+	for _, test := range tests {
+		e, err := ParseExpression(test.expression)
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if v := e.Evaluate(attributes, square); v != test.expected {
+			t.Errorf("%s: expected %#v, got %#v", test.expression, test.expected, v)
+		}
+	}
+
+	area, err := ParseExpression("AREA()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a 2x2 degree square at the equator is about 49,400 km²
+	if a := area.Evaluate(nil, square).(float64); math.Abs(a-4.94e10)/4.94e10 > 0.01 {
+		t.Errorf("expected area about 4.94e10, got %v", a)
+	}
+	if v := area.Evaluate(nil, nil); v != nil {
+		t.Errorf("expected null area without geometry, got %v", v)
+	}
+}
+
+func TestExpressionInvalid(t *testing.T) {
+	for _, s := range []string{"", "LOTSQFT /", "NOPE(LOTSQFT)", "ROUND()", "AREA(LOTSQFT)", "LOTSQFT = 1"} {
+		if _, err := ParseExpression(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+	for _, s := range []string{"LOTSQFT / 43560", "=LOTSQFT"} {
+		if _, err := ParseComputedProperty(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestConvertCompute(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [{"attributes": {"OBJECTID": 1, "LOTSQFT": 87120}, "geometry": {"points": [[1, 2]]}}]
+	}`
+	acres, err := ParseComputedProperty("acres = LOTSQFT / 43560")
+	if err != nil {
+		t.Fatal(err)
+	}
+	label, err := ParseComputedProperty("label=acres + ' acres'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := convertFeatureCollection(t, data, Options{Compute: []ComputedProperty{acres, label}})
+	props := fc.Features[0].Properties
+	if props["acres"] != 2.0 || props["label"] != "2 acres" {
+		t.Errorf("unexpected computed properties %v", props)
+	}
+
+	overflow, err := ParseComputedProperty("overflow = LOTSQFT * 1e308 * 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc = convertFeatureCollection(t, data, Options{Compute: []ComputedProperty{overflow}})
+	if v, ok := fc.Features[0].Properties["overflow"]; !ok || v != nil {
+		t.Errorf("expected a null overflow, got %v", fc.Features[0].Properties)
+	}
+}
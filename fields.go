@@ -0,0 +1,163 @@
+package arcgis2geojson
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// AliasCollision is a strategy for resolving two fields that share the same alias
+type AliasCollision int
+
+const (
+	// AliasCollisionSuffix appends _2, _3, ... to later colliding aliases
+	AliasCollisionSuffix AliasCollision = iota
+	// AliasCollisionFieldName keys later colliding fields by their field name
+	AliasCollisionFieldName
+	// AliasCollisionError fails the conversion
+	AliasCollisionError
+)
+
+// ParseAliasCollision parses an alias collision strategy name (suffix, name or error)
+func ParseAliasCollision(s string) (AliasCollision, error) {
+	switch strings.ToLower(s) {
+	case "", "suffix":
+		return AliasCollisionSuffix, nil
+	case "name", "fieldname":
+		return AliasCollisionFieldName, nil
+	case "error":
+		return AliasCollisionError, nil
+	}
+	return 0, fmt.Errorf("error: unknown alias collision strategy %q", s)
+}
+
+// aliasKeys maps each field name to its alias. aliases come from fields[].alias, falling back to
+// fieldAliases, falling back to the field name itself. fields are resolved in schema order so that
+// the first field to claim an alias keeps it, then attributes of the features that aren't in the
+// schema, which keep their name unless a field's alias already took it.
+func aliasKeys(arcgisJSON *ArcGISJSON, collision AliasCollision) (map[string]string, error) {
+	names := []string{}
+	aliases := map[string]string{}
+	for _, field := range arcgisJSON.Fields {
+		if _, ok := aliases[field.Name]; ok {
+			continue
+		}
+		alias := field.Alias
+		if alias == "" {
+			alias = arcgisJSON.FieldAliases[field.Name]
+		}
+		names = append(names, field.Name)
+		aliases[field.Name] = alias
+	}
+	// fields only listed in fieldAliases
+	extra := []string{}
+	for name := range arcgisJSON.FieldAliases {
+		if _, ok := aliases[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		names = append(names, name)
+		aliases[name] = arcgisJSON.FieldAliases[name]
+	}
+	// attributes that aren't in the schema
+	extra = []string{}
+	for _, f := range arcgisJSON.Features {
+		for name := range f.Attributes {
+			if _, ok := aliases[name]; !ok {
+				aliases[name] = ""
+				extra = append(extra, name)
+			}
+		}
+	}
+	sort.Strings(extra)
+	names = append(names, extra...)
+
+	keys := map[string]string{}
+	claimed := map[string]string{}
+	for _, name := range names {
+		key := aliases[name]
+		if key == "" {
+			key = name
+		}
+		if owner, ok := claimed[key]; ok {
+			switch collision {
+			case AliasCollisionError:
+				return nil, fmt.Errorf("error: fields %q and %q share the alias %q", owner, name, key)
+			case AliasCollisionFieldName:
+				key = name
+			}
+			base := key
+			for n := 2; ; n++ {
+				if _, ok := claimed[key]; !ok {
+					break
+				}
+				key = fmt.Sprintf("%s_%d", base, n)
+			}
+		}
+		claimed[key] = name
+		keys[name] = key
+	}
+	return keys, nil
+}
+
+// systemFieldPatterns match the names of esri system and editor tracking fields
+var systemFieldPatterns = []string{
+	"shape", "shape.*", "shape_*", "shape__*",
+	"st_area(*)", "st_length(*)", "st_perimeter(*)",
+	"globalid", "global_id",
+	"created_user", "created_date", "last_edited_user", "last_edited_date",
+	"creationdate", "creator", "editdate", "editor",
+}
+
+// isSystemField reports whether a field is an esri system or editor tracking field,
+// either by its type in the schema or by its name
+func isSystemField(name, fieldType string) bool {
+	switch fieldType {
+	case FieldTypeGeometry, FieldTypeRaster, FieldTypeGlobalID:
+		return true
+	}
+	return matchAny(systemFieldPatterns, name)
+}
+
+// matchAny reports whether name matches any of the case-insensitive glob patterns
+func matchAny(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePatterns checks that include/exclude glob patterns are well formed
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("error: invalid field pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// includeField reports whether an attribute is written to the properties
+func (c *converter) includeField(name string) bool {
+	if included, ok := c.included[name]; ok {
+		return included
+	}
+	included := true
+	if len(c.opts.Include) != 0 && !matchAny(c.opts.Include, name) {
+		included = false
+	}
+	if matchAny(c.opts.Exclude, name) {
+		included = false
+	}
+	if c.opts.DropSystemFields && isSystemField(name, c.fieldTypes[name]) {
+		included = false
+	}
+	c.included[name] = included
+	return included
+}
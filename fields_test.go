@@ -0,0 +1,106 @@
+package arcgis2geojson
+
+import "testing"
+
+const aliasData = `{
+	"fieldAliases": {"OBJECTID": "ObjectID", "PREUSE_DESC": "Present Use", "PREUSE_CODE": "Present Use", "ZIP5": ""},
+	"spatialReference": {"wkid": 4326},
+	"fields": [
+		{"name": "OBJECTID", "type": "esriFieldTypeOID", "alias": "ObjectID"},
+		{"name": "PREUSE_DESC", "type": "esriFieldTypeString", "alias": "Present Use"},
+		{"name": "PREUSE_CODE", "type": "esriFieldTypeSmallInteger", "alias": "Present Use"},
+		{"name": "ZIP5", "type": "esriFieldTypeString"}
+	],
+	"features": [
+		{"attributes": {"OBJECTID": 1, "PREUSE_DESC": "Vacant", "PREUSE_CODE": 316, "ZIP5": "98057"}, "geometry": {"points": [[1, 2]]}}
+	]
+}`
+
+func TestAliases(t *testing.T) {
+	tests := []struct {
+		collision AliasCollision
+		expected  map[string]interface{}
+	}{
+		{AliasCollisionSuffix, map[string]interface{}{"ObjectID": 1.0, "Present Use": "Vacant", "Present Use_2": 316.0, "ZIP5": "98057"}},
+		{AliasCollisionFieldName, map[string]interface{}{"ObjectID": 1.0, "Present Use": "Vacant", "PREUSE_CODE": 316.0, "ZIP5": "98057"}},
+	}
+	for _, test := range tests {
+		fc := convertFeatureCollection(t, aliasData, Options{UseAliases: true, AliasCollision: test.collision})
+		props := fc.Features[0].Properties
+		if len(props) != len(test.expected) {
+			t.Errorf("collision %d: expected %v, got %v", test.collision, test.expected, props)
+		}
+		for k, v := range test.expected {
+			if props[k] != v {
+				t.Errorf("collision %d: expected %s=%v, got %v", test.collision, k, v, props[k])
+			}
+		}
+	}
+}
+
+func TestAliasCollisionError(t *testing.T) {
+	_, err := ConvertWithOptions([]byte(aliasData), Options{UseAliases: true, AliasCollision: AliasCollisionError})
+	if err == nil {
+		t.Error("expected alias collision error")
+	}
+}
+
+func TestAliasCollisionAttribute(t *testing.T) {
+	// B isn't in the schema, but A's alias is B
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"fields": [{"name": "A", "type": "esriFieldTypeString", "alias": "B"}],
+		"features": [{"attributes": {"A": "a", "B": "b"}, "geometry": {"points": [[1, 2]]}}]
+	}`
+	for i := 0; i < 10; i++ {
+		fc := convertFeatureCollection(t, data, Options{UseAliases: true})
+		if props := fc.Features[0].Properties; len(props) != 2 || props["B"] != "a" || props["B_2"] != "b" {
+			t.Fatalf("expected A as B and B as B_2, got %v", props)
+		}
+	}
+	if _, err := ConvertWithOptions([]byte(data), Options{UseAliases: true, AliasCollision: AliasCollisionError}); err == nil {
+		t.Error("expected alias collision error")
+	}
+}
+
+func TestFieldFilters(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"fields": [
+			{"name": "OBJECTID", "type": "esriFieldTypeOID"},
+			{"name": "PIN", "type": "esriFieldTypeString"},
+			{"name": "ADDR_HN", "type": "esriFieldTypeString"},
+			{"name": "ADDR_FULL", "type": "esriFieldTypeString"},
+			{"name": "Shape__Length", "type": "esriFieldTypeDouble"},
+			{"name": "UID", "type": "esriFieldTypeGlobalID"},
+			{"name": "created_user", "type": "esriFieldTypeString"}
+		],
+		"features": [{
+			"attributes": {"OBJECTID": 1, "PIN": "a", "ADDR_HN": "737", "ADDR_FULL": "737 LOGAN", "Shape__Length": 3.2, "UID": "{A}", "created_user": "me"},
+			"geometry": {"points": [[1, 2]]}
+		}]
+	}`
+	tests := []struct {
+		opts     Options
+		expected []string
+	}{
+		{Options{DropSystemFields: true}, []string{"OBJECTID", "PIN", "ADDR_HN", "ADDR_FULL"}},
+		{Options{Include: []string{"addr_*", "PIN"}}, []string{"PIN", "ADDR_HN", "ADDR_FULL"}},
+		{Options{Include: []string{"ADDR_*"}, Exclude: []string{"*_HN"}}, []string{"ADDR_FULL"}},
+	}
+	for _, test := range tests {
+		fc := convertFeatureCollection(t, data, test.opts)
+		props := fc.Features[0].Properties
+		if len(props) != len(test.expected) {
+			t.Errorf("%+v: expected %v, got %v", test.opts, test.expected, props)
+		}
+		for _, k := range test.expected {
+			if _, ok := props[k]; !ok {
+				t.Errorf("%+v: expected %s in %v", test.opts, k, props)
+			}
+		}
+		if fc.Features[0].ID != 1.0 {
+			t.Errorf("%+v: expected id 1, got %v", test.opts, fc.Features[0].ID)
+		}
+	}
+}
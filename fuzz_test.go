@@ -0,0 +1,60 @@
+//go:build go1.18
+// +build go1.18
+
+package arcgis2geojson
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+var fuzzSeeds = []string{
+	`{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1]]}}]}`,
+	`{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"paths": [[]]}}]}`,
+	`{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [[]]}}]}`,
+	`{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [[[0, 0], [1], [1, 1], [0, 0]]]}}]}`,
+	`{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "x": 1, "y": 2}]}`,
+	`{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "xmin": 170, "ymin": 0, "xmax": -170, "ymax": 1}]}`,
+	`{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [
+		[[0, 0], [0, 10], [10, 10], [10, 0], [0, 0]], [[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]], [[0, 0], [10, 10], [10, 0], [0, 10], [0, 0]]
+	]}}]}`,
+	`{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"paths": [[[170, 0], [-170, 1], [-170, 1]]]}}]}`,
+}
+
+func FuzzConvert(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	bbox := orb.Bound{Min: orb.Point{-5, -5}, Max: orb.Point{5, 5}}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Convert(data, "OBJECTID")
+		ConvertWithOptions(data, Options{
+			InvalidFeatures:   InvalidFeaturesNullGeometry,
+			MakeValid:         true,
+			SplitAntimeridian: true,
+			Clip:              bbox,
+			Simplify:          SimplifyVisvalingam,
+			SimplifyTolerance: 0.1,
+			Precision:         3,
+			WriteBBox:         true,
+		})
+		Validate(data, Options{})
+	})
+}
+
+func FuzzConvertRingsToGeoJSON(f *testing.F) {
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`[[]]`))
+	f.Add([]byte(`[[[1]]]`))
+	f.Add([]byte(`[[[0, 0], [1, 1]]]`))
+	f.Add([]byte(`[[[0, 0], [0, 10], [10, 10], [10, 0], [0, 0]], [[4, 4], [6, 4], [6, 6], [4, 6], [4, 4]]]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rings := []Ring{}
+		if err := json.Unmarshal(data, &rings); err != nil {
+			return
+		}
+		convertRingsToGeoJSON(rings)
+	})
+}
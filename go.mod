@@ -1,6 +1,6 @@
 module github.com/engelsjk/arcgis2geojson
 
-go 1.12
+go 1.13
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.0 // indirect
@@ -11,4 +11,5 @@ require (
 	golang.org/x/crypto v0.0.0-20200221231518-2aa609cf4a9d // indirect
 	golang.org/x/sys v0.0.0-20200223170610-d5e6a3e2c0ae // indirect
 	golang.org/x/tools v0.0.0-20200221224223-e1da425f72fd // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,443 @@
+package arcgis2geojson
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// IDStrategy picks the id of a converted feature
+type IDStrategy interface {
+	ID(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error)
+}
+
+// IDStrategyFunc adapts a function to an IDStrategy
+type IDStrategyFunc func(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error)
+
+// ID calls fn
+func (fn IDStrategyFunc) ID(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error) {
+	return fn(f, layer)
+}
+
+var errNoID = errors.New("no valid id attribute found")
+
+// DefaultID is the id strategy used when none is given: the id attribute, then the OID field of the
+// schema, then objectIdFieldName, then OBJECTID and FID
+func DefaultID(idAttribute string) IDStrategy {
+	strategies := []IDStrategy{}
+	if idAttribute != "" {
+		strategies = append(strategies, AttributeID(idAttribute))
+	}
+	return FirstID(append(strategies, OIDFieldID(), ObjectIDFieldNameID(), AttributeID("OBJECTID"), AttributeID("FID"))...)
+}
+
+// AttributeID uses the value of an attribute, matching its name case-insensitively when there is no
+// exact match
+func AttributeID(name string) IDStrategy {
+	return IDStrategyFunc(func(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error) {
+		return getId(f.Attributes, name)
+	})
+}
+
+// OIDFieldID uses the attribute of the field with type esriFieldTypeOID
+func OIDFieldID() IDStrategy {
+	return IDStrategyFunc(func(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error) {
+		for _, field := range layer.Fields {
+			if field.Type == FieldTypeOID {
+				return getId(f.Attributes, field.Name)
+			}
+		}
+		return nil, errNoID
+	})
+}
+
+// ObjectIDFieldNameID uses the attribute named by objectIdFieldName in the response
+func ObjectIDFieldNameID() IDStrategy {
+	return IDStrategyFunc(func(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error) {
+		if layer.ObjectIDFieldName == "" {
+			return nil, errNoID
+		}
+		return getId(f.Attributes, layer.ObjectIDFieldName)
+	})
+}
+
+// CompositeID joins the values of several attributes, e.g. MAJOR and MINOR, with a separator
+func CompositeID(separator string, names ...string) IDStrategy {
+	return IDStrategyFunc(func(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error) {
+		parts := make([]string, len(names))
+		for i, name := range names {
+			v, err := getId(f.Attributes, name)
+			if err != nil || v == nil {
+				return nil, fmt.Errorf("composite id attribute %s not found", name)
+			}
+			parts[i] = fmt.Sprint(v)
+		}
+		return strings.Join(parts, separator), nil
+	})
+}
+
+// HashID uses a sha1 hash of the attributes and geometry, which is stable across runs for the same content
+func HashID() IDStrategy {
+	return IDStrategyFunc(func(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error) {
+		// json sorts map keys, so this encoding is canonical
+		b, err := json.Marshal(f)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha1.Sum(b)
+		return hex.EncodeToString(sum[:]), nil
+	})
+}
+
+// FirstID uses the first strategy that finds an id
+func FirstID(strategies ...IDStrategy) IDStrategy {
+	return IDStrategyFunc(func(f ArcGISFeature, layer *ArcGISJSON) (interface{}, error) {
+		for _, s := range strategies {
+			if id, err := s.ID(f, layer); err == nil && id != nil {
+				return id, nil
+			}
+		}
+		return nil, errNoID
+	})
+}
+
+// IDType forces the type of feature ids
+type IDType int
+
+const (
+	// IDTypeAuto keeps ids as they are
+	IDTypeAuto IDType = iota
+	// IDTypeString writes ids as strings
+	IDTypeString
+	// IDTypeNumber writes ids as numbers, dropping ids that aren't numeric
+	IDTypeNumber
+)
+
+// ParseIDType parses an id type name (auto, string or number)
+func ParseIDType(s string) (IDType, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return IDTypeAuto, nil
+	case "string":
+		return IDTypeString, nil
+	case "number", "numeric":
+		return IDTypeNumber, nil
+	}
+	return 0, fmt.Errorf("error: unknown id type %q", s)
+}
+
+// featureID picks the id of a feature using the configured strategy, prefix and type
+func (c *converter) featureID(f ArcGISFeature) (interface{}, error) {
+	id, err := c.idStrategy.ID(f, c.arcgisJSON)
+	if err != nil {
+		return nil, err
+	}
+	if id == nil {
+		return nil, errNoID
+	}
+	switch {
+	case c.opts.IDPrefix != "":
+		return c.opts.IDPrefix + idString(id), nil
+	case c.opts.IDType == IDTypeString:
+		return idString(id), nil
+	case c.opts.IDType == IDTypeNumber:
+		if i, ok := toInt64(id); ok {
+			return i, nil
+		}
+		if n, ok := toFloat64(id); ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("id %v is not numeric", id)
+	}
+	return id, nil
+}
+
+func idString(id interface{}) string {
+	if s, ok := toString(id).(string); ok {
+		return s
+	}
+	return fmt.Sprint(id)
+}
+
+// DuplicateIDPolicy decides what happens to features sharing an id
+type DuplicateIDPolicy int
+
+const (
+	// DuplicateIDsKeep keeps every feature as it is
+	DuplicateIDsKeep DuplicateIDPolicy = iota
+	// DuplicateIDsError fails the conversion
+	DuplicateIDsError
+	// DuplicateIDsKeepFirst drops all but the first feature with an id
+	DuplicateIDsKeepFirst
+	// DuplicateIDsKeepLast drops all but the last feature with an id
+	DuplicateIDsKeepLast
+	// DuplicateIDsRewrite gives later features with an id a new unique id. numeric ids continue from the
+	// largest numeric id, other ids get a -2, -3, ... suffix.
+	DuplicateIDsRewrite
+)
+
+var duplicateIDResolutions = map[DuplicateIDPolicy]string{
+	DuplicateIDsKeep:      "kept",
+	DuplicateIDsError:     "error",
+	DuplicateIDsKeepFirst: "kept first",
+	DuplicateIDsKeepLast:  "kept last",
+	DuplicateIDsRewrite:   "rewritten",
+}
+
+// ParseDuplicateIDPolicy parses a duplicate id policy name (keep, error, first, last or rewrite)
+func ParseDuplicateIDPolicy(s string) (DuplicateIDPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "keep":
+		return DuplicateIDsKeep, nil
+	case "error":
+		return DuplicateIDsError, nil
+	case "first", "keep-first":
+		return DuplicateIDsKeepFirst, nil
+	case "last", "keep-last":
+		return DuplicateIDsKeepLast, nil
+	case "rewrite":
+		return DuplicateIDsRewrite, nil
+	}
+	return 0, fmt.Errorf("error: unknown duplicate id policy %q", s)
+}
+
+// IDKey identifies an id regardless of how its number was decoded, so 7 and 7.0 are the same id
+// but 7 and "7" are not. integers are keyed exactly, so ids above 2^53 stay distinct.
+func IDKey(id interface{}) string {
+	switch n := id.(type) {
+	case string:
+		return "s" + n
+	case int:
+		return "i" + strconv.Itoa(n)
+	case int64:
+		return "i" + strconv.FormatInt(n, 10)
+	case uint64:
+		return "i" + strconv.FormatUint(n, 10)
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return "i" + strconv.FormatInt(i, 10)
+		}
+		if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+			return "i" + strconv.FormatUint(u, 10)
+		}
+		if f, err := n.Float64(); err == nil {
+			return floatKey(f)
+		}
+	case float64:
+		return floatKey(n)
+	}
+	return "s" + fmt.Sprint(id)
+}
+
+// floatKey keys a float id, as an integer when it has no fraction
+func floatKey(f float64) string {
+	if f == math.Trunc(f) && !math.IsInf(f, 0) {
+		return "i" + new(big.Float).SetFloat64(f).Text('f', 0)
+	}
+	return "n" + strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// maxID tracks the largest numeric id, so rewritten numeric ids can continue from it. it counts in
+// int64 while every numeric id is an integer, so rewritten ids above 2^53 aren't rounded.
+type maxID struct {
+	found   bool
+	integer bool
+	i       int64
+	f       float64
+}
+
+// add records an id
+func (m *maxID) add(id interface{}) {
+	if _, ok := id.(string); ok {
+		return
+	}
+	f, ok := toFloat64(id)
+	if !ok {
+		return
+	}
+	i, isInt := toInt64(id)
+	if !m.found {
+		m.found, m.integer, m.i, m.f = true, isInt, i, f
+		return
+	}
+	m.integer = m.integer && isInt
+	if isInt && i > m.i {
+		m.i = i
+	}
+	if f > m.f {
+		m.f = f
+	}
+}
+
+// next returns a new numeric id above every id recorded, or false when there is none
+func (m *maxID) next() (interface{}, bool) {
+	switch {
+	case !m.found:
+		return nil, false
+	case m.integer:
+		if m.i == math.MaxInt64 {
+			return nil, false
+		}
+		m.i++
+		m.f = float64(m.i)
+		return m.i, true
+	}
+	next := m.f + 1
+	if next == m.f || math.IsInf(next, 0) || math.IsNaN(next) {
+		return nil, false
+	}
+	m.f = next
+	return next, true
+}
+
+// DuplicateIDResolver applies a duplicate id policy to features one at a time, so features converted in
+// batches, like the pages of a query, can be resolved together
+type DuplicateIDResolver struct {
+	policy DuplicateIDPolicy
+	// input indexes of the features with each id, by IDKey, and the keys in the order they were first seen
+	ids  map[string][]int
+	keys []string
+	// the first id with each key
+	values map[string]interface{}
+	// ids of features still to come, which rewritten ids avoid
+	reserved map[string]bool
+	largest  maxID
+}
+
+// NewDuplicateIDResolver returns a resolver applying policy
+func NewDuplicateIDResolver(policy DuplicateIDPolicy) *DuplicateIDResolver {
+	return &DuplicateIDResolver{
+		policy:   policy,
+		ids:      map[string][]int{},
+		values:   map[string]interface{}{},
+		reserved: map[string]bool{},
+	}
+}
+
+// Reserve records the id of a feature that will be added later, so ids rewritten before then don't
+// take it
+func (r *DuplicateIDResolver) Reserve(id interface{}) {
+	if id == nil {
+		return
+	}
+	r.reserved[IDKey(id)] = true
+	r.largest.add(id)
+}
+
+// Add records the id of f, the index-th feature of the input, and applies the policy to it. it returns
+// false when the feature is dropped, and DuplicateIDsRewrite gives it a new id. DuplicateIDsError returns
+// a *DuplicateIDError for the first repeated id. DuplicateIDsKeepLast keeps every feature here, and
+// Last tells which to keep once every feature is added.
+func (r *DuplicateIDResolver) Add(f *geojson.Feature, index int) (bool, error) {
+	if f.ID == nil {
+		return true, nil
+	}
+	key := IDKey(f.ID)
+	previous := r.ids[key]
+	r.add(key, f.ID, index)
+	if len(previous) == 0 {
+		return true, nil
+	}
+	switch r.policy {
+	case DuplicateIDsError:
+		return false, &DuplicateIDError{ID: r.values[key], Features: append([]int{}, r.ids[key]...)}
+	case DuplicateIDsKeepFirst:
+		return false, nil
+	case DuplicateIDsRewrite:
+		f.ID = r.rewrite(f.ID)
+		r.add(IDKey(f.ID), f.ID, index)
+	}
+	return true, nil
+}
+
+// add records that the feature at index has an id
+func (r *DuplicateIDResolver) add(key string, id interface{}, index int) {
+	if _, ok := r.ids[key]; !ok {
+		r.keys = append(r.keys, key)
+		r.values[key] = id
+	}
+	r.ids[key] = append(r.ids[key], index)
+	r.largest.add(id)
+}
+
+// rewrite returns a new unique id for a repeated id. numeric ids continue from the largest numeric id,
+// other ids get a -2, -3, ... suffix.
+func (r *DuplicateIDResolver) rewrite(id interface{}) interface{} {
+	if _, ok := id.(string); !ok {
+		if _, ok := toFloat64(id); ok {
+			if next, ok := r.largest.next(); ok {
+				return next
+			}
+		}
+	}
+	for n := 2; ; n++ {
+		rewritten := fmt.Sprintf("%v-%d", id, n)
+		if key := IDKey(rewritten); len(r.ids[key]) == 0 && !r.reserved[key] {
+			return rewritten
+		}
+	}
+}
+
+// Last reports whether f, the index-th feature of the input, is the last feature added with its id
+func (r *DuplicateIDResolver) Last(f *geojson.Feature, index int) bool {
+	if f.ID == nil {
+		return true
+	}
+	indexes := r.ids[IDKey(f.ID)]
+	return len(indexes) == 0 || indexes[len(indexes)-1] == index
+}
+
+// DuplicateIDs returns the ids shared by more than one of the features added, in the order they were
+// first seen
+func (r *DuplicateIDResolver) DuplicateIDs() []DuplicateID {
+	duplicates := []DuplicateID{}
+	for _, key := range r.keys {
+		if indexes := r.ids[key]; len(indexes) > 1 {
+			duplicates = append(duplicates, DuplicateID{
+				ID: r.values[key], Features: append([]int{}, indexes...), Resolution: duplicateIDResolutions[r.policy],
+			})
+		}
+	}
+	return duplicates
+}
+
+// resolveDuplicateIDs finds features sharing an id, records them in the report and applies the
+// duplicate id policy. indexes are the input indexes of the features.
+func (c *converter) resolveDuplicateIDs(features []*geojson.Feature, indexes []int) ([]*geojson.Feature, error) {
+	resolver := NewDuplicateIDResolver(c.opts.DuplicateIDs)
+	for _, feature := range features {
+		resolver.Reserve(feature.ID)
+	}
+	kept := []*geojson.Feature{}
+	keptIndexes := []int{}
+	for i, feature := range features {
+		keep, err := resolver.Add(feature, indexes[i])
+		if err != nil {
+			c.report.DuplicateIDs = append(c.report.DuplicateIDs, resolver.DuplicateIDs()...)
+			return nil, err
+		}
+		if keep {
+			kept = append(kept, feature)
+			keptIndexes = append(keptIndexes, indexes[i])
+		}
+	}
+	c.report.DuplicateIDs = append(c.report.DuplicateIDs, resolver.DuplicateIDs()...)
+	if c.opts.DuplicateIDs != DuplicateIDsKeepLast {
+		return kept, nil
+	}
+	last := []*geojson.Feature{}
+	for i, feature := range kept {
+		if resolver.Last(feature, keptIndexes[i]) {
+			last = append(last, feature)
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,167 @@
+package arcgis2geojson
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+const idData = `{
+	"objectIdFieldName": "OID_1",
+	"spatialReference": {"wkid": 4326},
+	"fields": [
+		{"name": "OID_1", "type": "esriFieldTypeOID"},
+		{"name": "MAJOR", "type": "esriFieldTypeString"},
+		{"name": "MINOR", "type": "esriFieldTypeString"}
+	],
+	"features": [{"attributes": {"OID_1": 7, "MAJOR": "072305", "MINOR": "9046", "fid": 3}, "geometry": {"points": [[1, 2]]}}]
+}`
+
+func TestIDStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		expected interface{}
+	}{
+		{"default finds oid field", Options{}, 7.0},
+		{"case-insensitive attribute", Options{IDAttribute: "major"}, "072305"},
+		{"attribute", Options{IDStrategy: AttributeID("FID")}, 3.0},
+		{"oid field", Options{IDStrategy: OIDFieldID()}, 7.0},
+		{"objectIdFieldName", Options{IDStrategy: ObjectIDFieldNameID()}, 7.0},
+		{"composite", Options{IDStrategy: CompositeID("-", "MAJOR", "MINOR")}, "072305-9046"},
+		{"prefix", Options{IDPrefix: "parcel/"}, "parcel/7"},
+		{"string", Options{IDType: IDTypeString}, "7"},
+		{"number", Options{IDAttribute: "MINOR", IDType: IDTypeNumber}, 9046.0},
+	}
+	for _, test := range tests {
+		fc := convertFeatureCollection(t, idData, test.opts)
+		if id := fc.Features[0].ID; id != test.expected {
+			t.Errorf("%s: expected %#v, got %#v", test.name, test.expected, id)
+		}
+	}
+}
+
+func TestIDPrefixNumber(t *testing.T) {
+	if _, err := ConvertWithOptions([]byte(idData), Options{IDPrefix: "parcel/", IDType: IDTypeNumber}); err == nil {
+		t.Error("expected an error for an id prefix with a number id type")
+	}
+}
+
+func TestCaseInsensitiveIDOrder(t *testing.T) {
+	attributes := map[string]interface{}{"objectid": 1, "ObjectId": 2, "OBJECTID_1": 3, "OBJECTid": 4}
+	for i := 0; i < 10; i++ {
+		if id, err := getId(attributes, "OBJECTID"); err != nil || id != 4 {
+			t.Fatalf("expected the first match in sorted order, got %v, %v", id, err)
+		}
+	}
+}
+
+func TestHashID(t *testing.T) {
+	fc1 := convertFeatureCollection(t, idData, Options{IDStrategy: HashID()})
+	fc2 := convertFeatureCollection(t, idData, Options{IDStrategy: HashID()})
+	id, ok := fc1.Features[0].ID.(string)
+	if !ok || len(id) != 40 || id != fc2.Features[0].ID {
+		t.Errorf("expected a stable sha1 id, got %v and %v", fc1.Features[0].ID, fc2.Features[0].ID)
+	}
+}
+
+func TestDuplicateIDs(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1, "N": "a"}, "geometry": {"points": [[1, 2]]}},
+			{"attributes": {"OBJECTID": 2, "N": "b"}, "geometry": {"points": [[1, 2]]}},
+			{"attributes": {"OBJECTID": 1, "N": "c"}, "geometry": {"points": [[1, 2]]}},
+			{"attributes": {"OBJECTID": 1, "N": "d"}, "geometry": {"points": [[1, 2]]}}
+		]
+	}`
+	tests := []struct {
+		policy   DuplicateIDPolicy
+		expected []string
+		ids      []interface{}
+	}{
+		{DuplicateIDsKeep, []string{"a", "b", "c", "d"}, []interface{}{1.0, 2.0, 1.0, 1.0}},
+		{DuplicateIDsKeepFirst, []string{"a", "b"}, []interface{}{1.0, 2.0}},
+		{DuplicateIDsKeepLast, []string{"b", "d"}, []interface{}{2.0, 1.0}},
+		{DuplicateIDsRewrite, []string{"a", "b", "c", "d"}, []interface{}{1.0, 2.0, 3.0, 4.0}},
+	}
+	for _, test := range tests {
+		b, report, err := ConvertWithReport([]byte(data), Options{DuplicateIDs: test.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.DuplicateIDs) != 1 || len(report.DuplicateIDs[0].Features) != 3 {
+			t.Errorf("policy %d: expected one duplicate id in 3 features, got %+v", test.policy, report.DuplicateIDs)
+		}
+		fc, err := geojson.UnmarshalFeatureCollection(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fc.Features) != len(test.expected) {
+			t.Errorf("policy %d: expected %d features, got %d", test.policy, len(test.expected), len(fc.Features))
+			continue
+		}
+		for i, f := range fc.Features {
+			if f.Properties["N"] != test.expected[i] || f.ID != test.ids[i] {
+				t.Errorf("policy %d: feature %d: expected %s with id %v, got %v with id %v", test.policy, i, test.expected[i], test.ids[i], f.Properties["N"], f.ID)
+			}
+		}
+	}
+
+	if _, err := ConvertWithOptions([]byte(data), Options{DuplicateIDs: DuplicateIDsError}); err == nil {
+		t.Error("expected duplicate id error")
+	}
+}
+
+func TestDuplicateStringIDsRewrite(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"K": "a"}, "geometry": {"points": [[1, 2]]}},
+			{"attributes": {"K": "a-2"}, "geometry": {"points": [[1, 2]]}},
+			{"attributes": {"K": "a"}, "geometry": {"points": [[1, 2]]}}
+		]
+	}`
+	fc := convertFeatureCollection(t, data, Options{IDAttribute: "K", DuplicateIDs: DuplicateIDsRewrite})
+	ids := []interface{}{"a", "a-2", "a-3"}
+	for i, f := range fc.Features {
+		if f.ID != ids[i] {
+			t.Errorf("feature %d: expected id %v, got %v", i, ids[i], f.ID)
+		}
+	}
+}
+
+func TestLargeDuplicateIDs(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 9007199254740992}, "geometry": {"points": [[1, 2]]}},
+			{"attributes": {"OBJECTID": 9007199254740993}, "geometry": {"points": [[1, 2]]}},
+			{"attributes": {"OBJECTID": 9007199254740993}, "geometry": {"points": [[1, 2]]}}
+		]
+	}`
+	_, err := ConvertWithOptions([]byte(data), Options{DuplicateIDs: DuplicateIDsError})
+	var duplicate *DuplicateIDError
+	if !errors.As(err, &duplicate) || fmt.Sprint(duplicate.ID) != "9007199254740993" || fmt.Sprint(duplicate.Features) != "[1 2]" {
+		t.Errorf("expected only 9007199254740993 to be a duplicate, got %v", err)
+	}
+
+	b, err := ConvertWithOptions([]byte(data), Options{DuplicateIDs: DuplicateIDsKeepFirst})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"id":9007199254740992`) || !strings.Contains(string(b), `"id":9007199254740993`) {
+		t.Errorf("expected both ids to be kept, got %s", b)
+	}
+
+	b, err = ConvertWithOptions([]byte(data), Options{DuplicateIDs: DuplicateIDsRewrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"id":9007199254740994`) {
+		t.Errorf("expected the duplicate to be rewritten to 9007199254740994, got %s", b)
+	}
+}
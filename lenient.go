@@ -0,0 +1,110 @@
+package arcgis2geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InvalidFeaturePolicy decides what happens to features that fail to convert
+type InvalidFeaturePolicy int
+
+const (
+	// InvalidFeaturesError fails the conversion with a *FeatureError
+	InvalidFeaturesError InvalidFeaturePolicy = iota
+	// InvalidFeaturesSkip drops the feature and records a warning
+	InvalidFeaturesSkip
+	// InvalidFeaturesNullGeometry keeps the feature's properties and id with a null geometry and records
+	// a warning
+	InvalidFeaturesNullGeometry
+)
+
+// ParseInvalidFeaturePolicy parses an invalid feature policy name (error, skip or null)
+func ParseInvalidFeaturePolicy(s string) (InvalidFeaturePolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "error":
+		return InvalidFeaturesError, nil
+	case "skip":
+		return InvalidFeaturesSkip, nil
+	case "null":
+		return InvalidFeaturesNullGeometry, nil
+	}
+	return InvalidFeaturesError, fmt.Errorf("error: unknown invalid feature policy %q", s)
+}
+
+// invalidFeature handles a feature that failed to convert as the policy asks, returning the feature to
+// convert in its place, or false when it is dropped
+func (c *converter) invalidFeature(f ArcGISFeature, fe *FeatureError) (ArcGISFeature, bool) {
+	reason := fe.Err.Error()
+	if fe.Path != "" {
+		reason = fe.Path + ": " + reason
+	}
+	if c.opts.InvalidFeatures == InvalidFeaturesSkip {
+		c.warn(fe.Index, "skipped: %s", reason)
+		return f, false
+	}
+	c.warn(fe.Index, "geometry set to null: %s", reason)
+	return ArcGISFeature{Attributes: f.Attributes}, true
+}
+
+// geometryKeys are the members of a geometry object read by the conversion, or describing it
+var geometryKeys = map[string]bool{
+	"points":           true,
+	"paths":            true,
+	"rings":            true,
+	"spatialReference": true,
+	"hasZ":             true,
+	"hasM":             true,
+}
+
+// skipJSON ignores whatever json it is decoded from
+type skipJSON struct{}
+
+func (*skipJSON) UnmarshalJSON([]byte) error {
+	return nil
+}
+
+// UnmarshalJSON decodes a geometry, noting the members the conversion ignores as it goes so that the
+// input is only decoded once
+func (g *ArcGISGeometry) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	t, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return nil
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("error: geometry must be an object, got %s", data)
+	}
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := t.(string)
+		var v interface{}
+		// encoding/json matches member names case-insensitively, and so does this
+		switch strings.ToLower(key) {
+		case "points":
+			v = &g.Points
+		case "paths":
+			v = &g.Paths
+		case "rings":
+			v = &g.Rings
+		default:
+			v = &skipJSON{}
+			if !geometryKeys[key] {
+				g.unknownKeys = append(g.unknownKeys, key)
+			}
+		}
+		if err := decoder.Decode(v); err != nil {
+			return fmt.Errorf("error: geometry.%s: %v", key, err)
+		}
+	}
+	sort.Strings(g.unknownKeys)
+	return nil
+}
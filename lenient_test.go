@@ -0,0 +1,108 @@
+package arcgis2geojson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestInvalidFeatures(t *testing.T) {
+	data := `{"spatialReference": {"wkid": 4326}, "features": [
+		{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1, 2]]}},
+		{"attributes": {"OBJECTID": 2}, "geometry": {"paths": [[]]}},
+		{"attributes": {"OBJECTID": 3}, "geometry": {"points": [[3, 4]]}}
+	]}`
+
+	fc := convertFeatureCollection(t, data, Options{IDAttribute: "OBJECTID", InvalidFeatures: InvalidFeaturesSkip})
+	if len(fc.Features) != 2 || fc.Features[0].ID != float64(1) || fc.Features[1].ID != float64(3) {
+		t.Errorf("expected features 1 and 3, got %v", fc.Features)
+	}
+	_, report, err := ConvertWithReport([]byte(data), Options{InvalidFeatures: InvalidFeaturesSkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Warning{{Feature: 1, Message: "skipped: geometry.paths[0]: path is empty"}}
+	if !reflect.DeepEqual(report.Warnings, expected) {
+		t.Errorf("expected %v, got %v", expected, report.Warnings)
+	}
+
+	raw := convertRawFeatureCollection(t, data, Options{IDAttribute: "OBJECTID", InvalidFeatures: InvalidFeaturesNullGeometry})
+	if len(raw.Features) != 3 {
+		t.Fatalf("expected 3 features, got %d", len(raw.Features))
+	}
+	if f := raw.Features[1]; string(f.Geometry) != "null" || f.ID != float64(2) || f.Properties["OBJECTID"] != float64(2) {
+		t.Errorf("expected feature 2 with a null geometry, got %v", f)
+	}
+}
+
+func TestRingWarnings(t *testing.T) {
+	data := `{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [
+		[[0, 0], [0, 10], [10, 10], [10, 0], [0, 0]],
+		[[0, 0], [1, 1]],
+		[[20, 20], [21, 20], [21, 21], [20, 21], [20, 20]]
+	], "curveRings": [], "hasZ": false}}]}`
+	_, report, err := ConvertWithReport([]byte(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Warning{
+		{Feature: 0, Message: "ignored unsupported geometry keys curveRings"},
+		{Feature: 0, Message: "geometry.rings[1]: dropped degenerate ring"},
+		{Feature: 0, Message: "geometry.rings[2]: hole outside every outer ring became an outer ring"},
+	}
+	if !reflect.DeepEqual(report.Warnings, expected) {
+		t.Errorf("expected %v, got %v", expected, report.Warnings)
+	}
+}
+
+func TestEveryRingDropped(t *testing.T) {
+	data := `{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [
+		[[0, 0], [1, 1]],
+		[[2, 2], [3, 3], [2, 2]]
+	]}}]}`
+	raw := convertRawFeatureCollection(t, data, Options{})
+	if string(raw.Features[0].Geometry) != "null" {
+		t.Errorf("expected a null geometry, got %s", raw.Features[0].Geometry)
+	}
+	_, report, err := ConvertWithReport([]byte(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := report.Warnings[len(report.Warnings)-1]
+	if last.Message != "geometry.rings: every ring was dropped, geometry set to null" {
+		t.Errorf("expected a warning for the null geometry, got %v", report.Warnings)
+	}
+}
+
+func TestGeometryUnmarshal(t *testing.T) {
+	var f ArcGISFeature
+	data := `{"geometry": {"Rings": [[[0, 0], [0, 1], [1, 1], [0, 0]]], "spatialReference": {"wkid": 4326}, "curveRings": [], "ids": [1]}}`
+	if err := json.Unmarshal([]byte(data), &f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Geometry.Rings) != 1 || !reflect.DeepEqual(f.Geometry.unknownKeys, []string{"curveRings", "ids"}) {
+		t.Errorf("expected one ring and unknown keys curveRings, ids, got %v", f.Geometry)
+	}
+	if err := json.Unmarshal([]byte(`{"geometry": null}`), &f); err != nil {
+		t.Error(err)
+	}
+	if err := json.Unmarshal([]byte(`{"geometry": {"rings": "no"}}`), &f); err == nil {
+		t.Error("expected an error for malformed rings")
+	}
+}
+
+func TestParseInvalidFeaturePolicy(t *testing.T) {
+	for s, expected := range map[string]InvalidFeaturePolicy{
+		"":      InvalidFeaturesError,
+		"error": InvalidFeaturesError,
+		"skip":  InvalidFeaturesSkip,
+		"NULL":  InvalidFeaturesNullGeometry,
+	} {
+		if policy, err := ParseInvalidFeaturePolicy(s); err != nil || policy != expected {
+			t.Errorf("%q: expected %v, got %v %v", s, expected, policy, err)
+		}
+	}
+	if _, err := ParseInvalidFeaturePolicy("drop"); err == nil {
+		t.Error("expected an error")
+	}
+}
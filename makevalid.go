@@ -0,0 +1,369 @@
+package arcgis2geojson
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// IssueRingOrientation is a repair of outer rings that aren't counterclockwise or holes that aren't
+// clockwise
+const IssueRingOrientation = "ring-orientation"
+
+// MakeValid repairs a geometry into a valid simple features geometry. it drops invalid coordinates,
+// duplicate vertices, spikes and lines with fewer than two points. polygons are rebuilt from the even-odd fill
+// of all their rings, which splits bow ties, removes spikes, fixes ring orientation and puts holes in
+// the outer ring containing them. holes outside every outer ring become polygons, and parts of a
+// multipolygon that overlap have the overlap cut out. valid geometries are returned as they are, and
+// nil is returned when nothing valid is left.
+func MakeValid(g orb.Geometry) orb.Geometry {
+	switch t := g.(type) {
+	case orb.Point:
+		if validCoordinate(t) {
+			return t
+		}
+		return nil
+	case orb.MultiPoint:
+		points := orb.MultiPoint{}
+		for _, pt := range t {
+			if validCoordinate(pt) {
+				points = append(points, pt)
+			}
+		}
+		if len(points) == len(t) {
+			return t
+		}
+		return multiPoint(points)
+	case orb.LineString:
+		if ls := cleanLine(t); ls != nil {
+			return ls
+		}
+		return nil
+	case orb.MultiLineString:
+		lines := orb.MultiLineString{}
+		for _, ls := range t {
+			if ls = cleanLine(ls); ls != nil {
+				lines = append(lines, ls)
+			}
+		}
+		return multiLineString(lines)
+	case orb.Polygon:
+		return multiPolygon(repairPolygons(orb.MultiPolygon{t}))
+	case orb.MultiPolygon:
+		return multiPolygon(repairPolygons(t))
+	}
+	return g
+}
+
+// cleanLine drops invalid coordinates, repeated points and spikes from a line, returning nil when fewer
+// than two points are left
+func cleanLine(ls orb.LineString) orb.LineString {
+	clean := orb.LineString{}
+	for _, pt := range ls {
+		if validCoordinate(pt) {
+			clean = append(clean, pt)
+		}
+	}
+	for {
+		clean = dedupe(clean)
+		spiked := spikes(clean)
+		if len(spiked) == 0 {
+			break
+		}
+		drop := map[int]bool{}
+		for _, i := range spiked {
+			drop[i] = true
+		}
+		kept := orb.LineString{}
+		for i, pt := range clean {
+			if !drop[i] {
+				kept = append(kept, pt)
+			}
+		}
+		clean = kept
+	}
+	switch {
+	case len(clean) < 2:
+		return nil
+	case len(clean) == len(ls):
+		return ls
+	}
+	return clean
+}
+
+// dedupe drops repeated points
+func dedupe(ls orb.LineString) orb.LineString {
+	deduped := orb.LineString{}
+	for _, pt := range ls {
+		if len(deduped) == 0 || pt != deduped[len(deduped)-1] {
+			deduped = append(deduped, pt)
+		}
+	}
+	return deduped
+}
+
+// polygonsValid reports whether polygons are valid as they are: every ring is valid and correctly
+// oriented, rings only meet rings of the same polygon at a point, and no polygon starts inside another
+func polygonsValid(mp orb.MultiPolygon) bool {
+	for _, issue := range ValidateGeometry(mp) {
+		if issue.Kind != IssueOutOfRange {
+			return false
+		}
+	}
+	if len(orientationRepairs(mp)) != 0 {
+		return false
+	}
+	// the edges of every ring, with the ring and polygon they belong to
+	edges, ring, owner := []edge{}, []int{}, []int{}
+	rings := 0
+	for i, p := range mp {
+		for _, r := range p {
+			for _, e := range pathEdges(r) {
+				edges = append(edges, e)
+				ring = append(ring, rings)
+				owner = append(owner, i)
+			}
+			rings++
+		}
+	}
+	valid := true
+	overlappingEdges(edges, func(i, j int) {
+		if !valid || ring[i] == ring[j] {
+			return
+		}
+		p, q := edges[i], edges[j]
+		onP, onQ := edgeCuts(p, q)
+		if len(onP)+len(onQ) == 0 {
+			return
+		}
+		if owner[i] != owner[j] || len(onP) != 1 || len(onQ) != 1 || onP[0] != onQ[0] {
+			valid = false
+			return
+		}
+		if pt := onP[0]; pt != p[0] && pt != p[1] && pt != q[0] && pt != q[1] {
+			// crossing
+			valid = false
+		}
+	})
+	if !valid {
+		return false
+	}
+	for i, p := range mp {
+		for j, other := range mp {
+			if i != j && polygonContainsPoint(other, []float64{p[0][0][0], p[0][0][1]}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// orientationRepairs returns the rings of polygons wound the wrong way
+func orientationRepairs(mp orb.MultiPolygon) []orb.Ring {
+	wrong := []orb.Ring{}
+	for _, p := range mp {
+		for i, r := range p {
+			want := orb.CW
+			if i == 0 {
+				want = orb.CCW
+			}
+			if len(r) >= minRingPoints && r.Orientation() != want {
+				wrong = append(wrong, r)
+			}
+		}
+	}
+	return wrong
+}
+
+// repairPolygons rebuilds polygons from the even-odd fill of their rings. the edges of all rings are
+// split where they meet, edges covered an even number of times cancel out, and each remaining edge is
+// turned so the filled side is on its left before the edges are chained back into rings.
+func repairPolygons(mp orb.MultiPolygon) orb.MultiPolygon {
+	if polygonsValid(mp) {
+		return mp
+	}
+	edges := []edge{}
+	for _, p := range mp {
+		for _, r := range p {
+			valid := true
+			for _, pt := range r {
+				valid = valid && validCoordinate(pt)
+			}
+			if !valid || len(r) == 0 {
+				continue
+			}
+			if !r.Closed() {
+				r = append(r[:len(r):len(r)], r[0])
+			}
+			edges = append(edges, pathEdges(r)...)
+		}
+	}
+	pieces := splitSelf(edges)
+	rows, columns := newBands(pieces, 1), newBands(pieces, 0)
+
+	// pieces covering the same segment, in either direction
+	groups := map[edge]int{}
+	for _, e := range pieces {
+		groups[undirected(e)]++
+	}
+	kept := []edge{}
+	seen := map[edge]bool{}
+	for _, e := range pieces {
+		key := undirected(e)
+		if seen[key] || groups[key]%2 == 0 {
+			continue
+		}
+		seen[key] = true
+		// the parity of a ray from the middle of the edge, not counting the edge itself, is the parity of
+		// the side the ray leaves from. the filled side has odd parity.
+		mid := e.midpoint()
+		d := sub(e[1], e[0])
+		var forward bool
+		if d[1] != 0 {
+			// a ray towards +x leaves from the right of edges going up and the left of edges going down
+			odd := rayCrossings(pieces, rows.at(mid[1]), key, mid, false)%2 == 1
+			forward = odd == (d[1] < 0)
+		} else {
+			// a ray towards +y leaves from the left of edges going right
+			odd := rayCrossings(pieces, columns.at(mid[0]), key, mid, true)%2 == 1
+			forward = odd == (d[0] > 0)
+		}
+		if forward {
+			kept = append(kept, e)
+		} else {
+			kept = append(kept, e.reversed())
+		}
+	}
+	return assembleRings(kept)
+}
+
+// undirected returns an edge with its ends in a fixed order
+func undirected(e edge) edge {
+	if e[1][0] < e[0][0] || (e[1][0] == e[0][0] && e[1][1] < e[0][1]) {
+		return e.reversed()
+	}
+	return e
+}
+
+// rayCrossings counts the edges, out of those at the candidate indexes and other than those covering
+// skip, crossed by a ray from pt towards +x, or +y when vertical is set
+func rayCrossings(edges []edge, candidates []int, skip edge, pt []float64, vertical bool) int {
+	x, y := 0, 1
+	if vertical {
+		x, y = 1, 0
+	}
+	crossings := 0
+	for _, i := range candidates {
+		e := edges[i]
+		if undirected(e) == skip {
+			continue
+		}
+		a, b := e[0], e[1]
+		if (a[y] <= pt[y] && pt[y] < b[y]) || (b[y] <= pt[y] && pt[y] < a[y]) {
+			if pt[x] < a[x]+(pt[y]-a[y])*(b[x]-a[x])/(b[y]-a[y]) {
+				crossings++
+			}
+		}
+	}
+	return crossings
+}
+
+// bands indexes edges by the bands of one coordinate they span, so a ray along the other axis only
+// looks at the edges spanning the band it starts in
+type bands struct {
+	min   float64
+	width float64
+	edges [][]int
+}
+
+// newBands indexes edges by their y, or x when axis is 0, in about one band per 8 edges
+func newBands(edges []edge, axis int) *bands {
+	b := &bands{edges: make([][]int, len(edges)/8+1)}
+	if len(edges) == 0 {
+		return b
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, e := range edges {
+		lo = math.Min(lo, math.Min(e[0][axis], e[1][axis]))
+		hi = math.Max(hi, math.Max(e[0][axis], e[1][axis]))
+	}
+	b.min, b.width = lo, (hi-lo)/float64(len(b.edges))
+	for i, e := range edges {
+		from := b.band(math.Min(e[0][axis], e[1][axis]))
+		to := b.band(math.Max(e[0][axis], e[1][axis]))
+		for k := from; k <= to; k++ {
+			b.edges[k] = append(b.edges[k], i)
+		}
+	}
+	return b
+}
+
+// band returns the band of a coordinate
+func (b *bands) band(v float64) int {
+	if b.width == 0 {
+		return 0
+	}
+	k := int((v - b.min) / b.width)
+	if k < 0 {
+		return 0
+	}
+	if k >= len(b.edges) {
+		return len(b.edges) - 1
+	}
+	return k
+}
+
+// at returns the indexes of the edges that may span a coordinate
+func (b *bands) at(v float64) []int {
+	return b.edges[b.band(v)]
+}
+
+// makeValid repairs a converted geometry and records the kinds of problems repaired
+func (c *converter) makeValid(g orb.Geometry, index int) orb.Geometry {
+	repaired := MakeValid(g)
+	if repaired != nil && orb.Equal(repaired, g) {
+		return g
+	}
+	before, kinds := geometryProblems(g)
+	after, _ := geometryProblems(repaired)
+	fixed := 0
+	for _, kind := range kinds {
+		if n := before[kind] - after[kind]; n > 0 {
+			c.report.Repairs = append(c.report.Repairs, Repair{Feature: index, Kind: kind, Count: n})
+			fixed++
+		}
+	}
+	if fixed == 0 {
+		// rings of different parts crossing or overlapping
+		c.report.Repairs = append(c.report.Repairs, Repair{Feature: index, Kind: IssueSelfIntersection, Count: 1})
+	}
+	if repaired == nil {
+		c.warn(index, "nothing valid is left of the geometry")
+	}
+	return repaired
+}
+
+// geometryProblems counts the issues and wrongly oriented rings of a geometry by kind, in the order
+// they are found
+func geometryProblems(g orb.Geometry) (map[string]int, []string) {
+	counts := map[string]int{}
+	kinds := []string{}
+	record := func(kind string) {
+		if counts[kind] == 0 {
+			kinds = append(kinds, kind)
+		}
+		counts[kind]++
+	}
+	if g == nil {
+		return counts, kinds
+	}
+	for _, issue := range ValidateGeometry(g) {
+		if issue.Kind != IssueOutOfRange {
+			record(issue.Kind)
+		}
+	}
+	for range orientationRepairs(geometryPolygons(g)) {
+		record(IssueRingOrientation)
+	}
+	return counts, kinds
+}
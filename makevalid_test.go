@@ -0,0 +1,149 @@
+package arcgis2geojson
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+func TestMakeValid(t *testing.T) {
+	square := orb.Ring{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	hole := orb.Ring{{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}}
+	tests := []struct {
+		name     string
+		g        orb.Geometry
+		polygons int
+		rings    int
+		area     float64
+	}{
+		{"valid", orb.Polygon{square, hole}, 1, 2, 96},
+		{"bow tie", orb.Polygon{{{0, 0}, {10, 10}, {10, 0}, {0, 10}, {0, 0}}}, 2, 2, 50},
+		{"clockwise shell", orb.Polygon{{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}}}, 1, 1, 100},
+		{"counterclockwise hole", orb.Polygon{square, {{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}}}, 1, 2, 96},
+		{"spike", orb.Polygon{{{0, 0}, {10, 0}, {10, 10}, {10, 20}, {10, 10}, {0, 10}, {0, 0}}}, 1, 1, 100},
+		{"duplicate points", orb.Polygon{{{0, 0}, {10, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}}, 1, 1, 100},
+		{"hole outside", orb.Polygon{square, {{20, 20}, {20, 21}, {21, 21}, {21, 20}, {20, 20}}}, 2, 2, 101},
+		{"hole in another part", orb.MultiPolygon{{square}, {{{20, 0}, {30, 0}, {30, 10}, {20, 10}, {20, 0}}, hole}}, 2, 3, 196},
+		{"shell as hole", orb.MultiPolygon{{square}, {{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}}}}, 1, 2, 96},
+		// the part of the hole outside the shell is filled
+		{"hole crossing the shell", orb.Polygon{square, {{8, 4}, {8, 6}, {12, 6}, {12, 4}, {8, 4}}}, 2, 2, 100},
+	}
+	for _, test := range tests {
+		g := MakeValid(test.g)
+		if g == nil {
+			t.Errorf("%s: expected a geometry", test.name)
+			continue
+		}
+		polygons := geometryPolygons(g)
+		rings := 0
+		for _, p := range polygons {
+			rings += len(p)
+		}
+		if len(polygons) != test.polygons || rings != test.rings {
+			t.Errorf("%s: expected %d polygons with %d rings, got %v", test.name, test.polygons, test.rings, g)
+		}
+		if area := planar.Area(g); math.Abs(area-test.area) > 1e-9 {
+			t.Errorf("%s: expected area %v, got %v", test.name, test.area, area)
+		}
+		if issues := ValidateGeometry(g); len(issues) != 0 {
+			t.Errorf("%s: expected a valid geometry, got %v", test.name, issues)
+		}
+		if wrong := orientationRepairs(polygons); len(wrong) != 0 {
+			t.Errorf("%s: expected rings to be oriented, got %v", test.name, wrong)
+		}
+	}
+
+	valid := orb.Polygon{square, hole}
+	if g := MakeValid(valid); !orb.Equal(g, valid) {
+		t.Errorf("expected a valid polygon to be kept as it is, got %v", g)
+	}
+	if g := MakeValid(orb.LineString{{0, 0}, {0, 0}, {1, 1}, {1, 1}}); !orb.Equal(g, orb.LineString{{0, 0}, {1, 1}}) {
+		t.Errorf("expected duplicate points to be dropped, got %v", g)
+	}
+	if g := MakeValid(orb.LineString{{0, 0}, {5, 5}, {3, 3}, {3, 0}}); !orb.Equal(g, orb.LineString{{0, 0}, {3, 3}, {3, 0}}) {
+		t.Errorf("expected the spike to be dropped, got %v", g)
+	}
+	if g := MakeValid(orb.LineString{{0, 0}, {0, 0}}); g != nil {
+		t.Errorf("expected a line of one point to be dropped, got %v", g)
+	}
+	if g := MakeValid(orb.Polygon{{{0, 0}, {5, 0}, {10, 0}, {0, 0}}}); g != nil {
+		t.Errorf("expected a ring without area to be dropped, got %v", g)
+	}
+}
+
+func TestConvertMakeValid(t *testing.T) {
+	// the inner ring is clockwise like an outer ring, so it becomes an overlapping polygon instead of a hole
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [
+				[[0, 0], [0, 10], [10, 10], [10, 0], [0, 0]],
+				[[4, 4], [4, 6], [6, 6], [6, 4], [4, 4]]
+			]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"rings": [[[0, 0], [0, 10], [10, 10], [10, 0], [0, 0]]]}}
+		]
+	}`
+	_, report, err := ConvertWithReport([]byte(data), Options{MakeValid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Repairs) != 1 || report.Repairs[0].Feature != 0 {
+		t.Fatalf("expected a repair of feature 0, got %v", report.Repairs)
+	}
+	fc := convertFeatureCollection(t, data, Options{MakeValid: true})
+	p, ok := fc.Features[0].Geometry.(orb.Polygon)
+	if !ok || len(p) != 2 || planar.Area(p) != 96 {
+		t.Errorf("expected a polygon with a hole, got %v", fc.Features[0].Geometry)
+	}
+}
+
+func TestMakeValidRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	ring := func(n int) orb.Ring {
+		r := orb.Ring{}
+		for i := 0; i < n; i++ {
+			r = append(r, orb.Point{float64(random.Intn(11)), float64(random.Intn(11))})
+		}
+		return append(r, r[0])
+	}
+	for i := 0; i < 3000; i++ {
+		p := orb.Polygon{ring(4 + random.Intn(5))}
+		if random.Intn(3) == 0 {
+			p = append(p, ring(4+random.Intn(3)))
+		}
+		g := MakeValid(p)
+		if g == nil {
+			continue
+		}
+		for _, issue := range ValidateGeometry(g) {
+			if issue.Kind != IssueOutOfRange {
+				t.Errorf("%v: expected a valid geometry, got %v: %s", p, g, issue.Message)
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkMakeValid(b *testing.B) {
+	p := orb.Polygon{circle(20000)}
+	for i := 0; i < b.N; i++ {
+		if g := MakeValid(p); !orb.Equal(g, p) {
+			b.Fatal("expected the valid polygon to be kept")
+		}
+	}
+}
+
+func BenchmarkMakeValidRepair(b *testing.B) {
+	// a bow tie where two vertices are swapped
+	r := circle(20000)
+	r[100], r[101] = r[101], r[100]
+	p := orb.Polygon{r}
+	for i := 0; i < b.N; i++ {
+		if g := MakeValid(p); len(ValidateGeometry(g)) != 0 {
+			b.Fatal("expected a valid geometry")
+		}
+	}
+}
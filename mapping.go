@@ -0,0 +1,238 @@
+package arcgis2geojson
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// FieldMapping is a per field transform of attributes, usually loaded from a json or yaml config file:
+//
+//	fields:
+//	  PREUSE_DESC:
+//	    rename: use
+//	    values: {"Vacant(Industrial)": vacant}
+//	  LOTSQFT:
+//	    type: int
+//	    default: 0
+//	  Shape.area:
+//	    drop: true
+//
+// if any rule sets keep, fields without a keep rule are dropped.
+type FieldMapping struct {
+	Fields map[string]FieldRule `json:"fields" yaml:"fields"`
+}
+
+// FieldRule is the transform of a single field. the value map is applied first, then the default,
+// then the type cast, then the rename.
+type FieldRule struct {
+	// Rename is the output property key
+	Rename string `json:"rename" yaml:"rename"`
+	// Drop removes the field from the output
+	Drop bool `json:"drop" yaml:"drop"`
+	// Keep keeps the field when only kept fields are output
+	Keep bool `json:"keep" yaml:"keep"`
+	// Type casts the value to int, float, string, bool or an esri field type. the value is first coerced
+	// to the type of its field in the schema, whether or not CoerceTypes is set.
+	Type string `json:"type" yaml:"type"`
+	// Default replaces null or missing values
+	Default interface{} `json:"default" yaml:"default"`
+	// Values maps input values, compared as strings, to output values
+	Values map[string]interface{} `json:"values" yaml:"values"`
+}
+
+// LoadFieldMapping reads a json or yaml field mapping file
+func LoadFieldMapping(path string) (*FieldMapping, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFieldMapping(data)
+}
+
+// ParseFieldMapping parses a json or yaml field mapping
+func ParseFieldMapping(data []byte) (*FieldMapping, error) {
+	// json is valid yaml, so one parser covers both
+	m := &FieldMapping{}
+	if err := yaml.UnmarshalStrict(data, m); err != nil {
+		return nil, fmt.Errorf("error: invalid field mapping: %v", err)
+	}
+	for name, rule := range m.Fields {
+		if rule.Type != "" && castFieldType(rule.Type) == "" {
+			return nil, fmt.Errorf("error: invalid field mapping: unknown type %q for field %q", rule.Type, name)
+		}
+		rule.Default = yamlToJSON(rule.Default)
+		for k, v := range rule.Values {
+			rule.Values[k] = yamlToJSON(v)
+		}
+		m.Fields[name] = rule
+	}
+	return m, nil
+}
+
+// Apply transforms attributes keyed by field name into properties. it fails when two fields map to
+// the same property key.
+func (m *FieldMapping) Apply(attributes map[string]interface{}) (map[string]interface{}, error) {
+	identity := func(name string) string { return name }
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	if err := m.checkKeys(names, identity); err != nil {
+		return nil, err
+	}
+	props := map[string]interface{}{}
+	for name, v := range attributes {
+		if key, v, ok := m.apply(name, name, "", v); ok {
+			props[key] = v
+		}
+	}
+	m.applyDefaults(attributes, props, identity, nil)
+	return props, nil
+}
+
+// key returns the output key of a field, or false if it is dropped
+func (m *FieldMapping) key(name, key string) (string, bool) {
+	rule := m.Fields[name]
+	if rule.Drop || (!rule.Keep && m.keepOnly()) {
+		return "", false
+	}
+	if rule.Rename != "" {
+		key = rule.Rename
+	}
+	return key, true
+}
+
+// checkKeys returns an error if two of the named fields, or two fields with a default, map to the same
+// property key
+func (m *FieldMapping) checkKeys(names []string, propertyKey func(string) string) error {
+	for name, rule := range m.Fields {
+		if rule.Default != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	fields := map[string]string{}
+	for _, name := range names {
+		key, ok := m.key(name, propertyKey(name))
+		if !ok {
+			continue
+		}
+		if other, ok := fields[key]; ok && other != name {
+			return fmt.Errorf("error: field mapping maps fields %q and %q to the same property %q", other, name, key)
+		}
+		fields[key] = name
+	}
+	return nil
+}
+
+// apply transforms a single attribute, returning its output key and value, or false if it is dropped.
+// fieldType is the type of the field in the schema, which the value is coerced to before a type cast.
+func (m *FieldMapping) apply(name, key, fieldType string, v interface{}) (string, interface{}, bool) {
+	key, ok := m.key(name, key)
+	if !ok {
+		return "", nil, false
+	}
+	rule, ok := m.Fields[name]
+	if !ok {
+		return key, v, true
+	}
+	if v != nil && rule.Values != nil {
+		if mapped, ok := rule.Values[fmt.Sprint(v)]; ok {
+			v = mapped
+		}
+	}
+	if v == nil {
+		v = rule.Default
+	}
+	if rule.Type != "" {
+		v = cast(coerce(v, fieldType), rule.Type)
+	}
+	return key, v, true
+}
+
+// applyDefaults adds the default value of fields missing from attributes
+func (m *FieldMapping) applyDefaults(attributes, props map[string]interface{}, propertyKey func(string) string, fieldTypes map[string]string) {
+	for name, rule := range m.Fields {
+		if _, ok := attributes[name]; ok || rule.Default == nil {
+			continue
+		}
+		if key, v, ok := m.apply(name, propertyKey(name), fieldTypes[name], nil); ok {
+			props[key] = v
+		}
+	}
+}
+
+func (m *FieldMapping) keepOnly() bool {
+	for _, rule := range m.Fields {
+		if rule.Keep {
+			return true
+		}
+	}
+	return false
+}
+
+// castFieldType returns the esri field type for a cast type name, or "" if it is unknown
+func castFieldType(t string) string {
+	switch strings.ToLower(t) {
+	case "int", "integer", "long":
+		return FieldTypeBigInteger
+	case "float", "double", "number":
+		return FieldTypeDouble
+	case "string", "str":
+		return FieldTypeString
+	case "bool", "boolean":
+		return "bool"
+	}
+	switch t {
+	case FieldTypeSmallInteger, FieldTypeInteger, FieldTypeBigInteger, FieldTypeSingle, FieldTypeDouble,
+		FieldTypeString, FieldTypeDate, FieldTypeOID, FieldTypeBlob, FieldTypeGUID, FieldTypeGlobalID, FieldTypeXML:
+		return t
+	}
+	return ""
+}
+
+func cast(v interface{}, t string) interface{} {
+	fieldType := castFieldType(t)
+	if fieldType != "bool" {
+		return coerce(v, fieldType)
+	}
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		switch strings.ToLower(strings.TrimSpace(b)) {
+		case "true", "t", "yes", "y", "1":
+			return true
+		case "false", "f", "no", "n", "0", "":
+			return false
+		}
+	default:
+		if f, ok := toFloat64(v); ok {
+			return f != 0
+		}
+	}
+	return v
+}
+
+// yamlToJSON converts yaml maps, which have interface{} keys, to maps json can encode
+func yamlToJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range t {
+			m[fmt.Sprint(k)] = yamlToJSON(v)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = yamlToJSON(t[i])
+		}
+	case int:
+		return int64(t)
+	}
+	return v
+}
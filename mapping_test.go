@@ -0,0 +1,116 @@
+package arcgis2geojson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFieldMapping(t *testing.T) {
+	m, err := ParseFieldMapping([]byte(`
+fields:
+  PREUSE_DESC:
+    rename: use
+    values:
+      "Vacant(Industrial)": vacant
+  LOTSQFT:
+    type: float
+  ZONING:
+    default: none
+  Shape.area:
+    drop: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, err := m.Apply(map[string]interface{}{
+		"PREUSE_DESC": "Vacant(Industrial)",
+		"LOTSQFT":     json.Number("101141"),
+		"Shape.area":  json.Number("101139.1"),
+		"PIN":         "0723059046",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"use":     "vacant",
+		"LOTSQFT": 101141.0,
+		"ZONING":  "none",
+		"PIN":     "0723059046",
+	}
+	if len(props) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, props)
+	}
+	for k, v := range expected {
+		if props[k] != v {
+			t.Errorf("expected %s=%#v, got %#v", k, v, props[k])
+		}
+	}
+}
+
+func TestFieldMappingKeep(t *testing.T) {
+	m, err := ParseFieldMapping([]byte(`{"fields": {"PIN": {"keep": true, "type": "int"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, err := m.Apply(map[string]interface{}{"PIN": "0723059046", "MINOR": "9046"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props) != 1 || props["PIN"] != int64(723059046) {
+		t.Errorf("expected only PIN as int, got %v", props)
+	}
+}
+
+func TestFieldMappingInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"fields": {"PIN": {"type": "date-ish"}}}`,
+		`{"fields": {"PIN": {"renam": "pin"}}}`,
+	} {
+		if _, err := ParseFieldMapping([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
+
+func TestFieldMappingCollision(t *testing.T) {
+	m, err := ParseFieldMapping([]byte(`{"fields": {"PREUSE_DESC": {"rename": "use"}, "PREUSE_CODE": {"rename": "use"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Apply(map[string]interface{}{"PREUSE_DESC": "Vacant", "PREUSE_CODE": 316}); err == nil {
+		t.Error("expected collision error from Apply")
+	}
+	if _, err := ConvertWithOptions([]byte(aliasData), Options{FieldMapping: m}); err == nil {
+		t.Error("expected collision error from a rename")
+	}
+
+	// a rename onto the alias of another field
+	m, err = ParseFieldMapping([]byte(`{"fields": {"ZIP5": {"rename": "ObjectID"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ConvertWithOptions([]byte(aliasData), Options{UseAliases: true, FieldMapping: m}); err == nil {
+		t.Error("expected collision error from a rename onto an alias")
+	}
+	if _, err := ConvertWithOptions([]byte(aliasData), Options{FieldMapping: m}); err != nil {
+		t.Errorf("expected no collision without aliases, got %v", err)
+	}
+}
+
+func TestFieldMappingCastUsesSchema(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"fields": [{"name": "LOTSQFT", "type": "esriFieldTypeDouble"}],
+		"features": [{"attributes": {"LOTSQFT": "101141.50"}, "geometry": {"points": [[1, 2]]}}]
+	}`
+	m, err := ParseFieldMapping([]byte(`{"fields": {"LOTSQFT": {"type": "string"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, coerceTypes := range []bool{false, true} {
+		fc := convertFeatureCollection(t, data, Options{FieldMapping: m, CoerceTypes: coerceTypes})
+		if v := fc.Features[0].Properties["LOTSQFT"]; v != "101141.5" {
+			t.Errorf("coerce types %v: expected LOTSQFT=101141.5, got %#v", coerceTypes, v)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package arcgis2geojson
+
+import (
+	"fmt"
+
+	"github.com/paulmach/orb"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// Options configures a conversion
+type Options struct {
+	// IDAttribute is the attribute used as the feature id
+	IDAttribute string
+	// IDStrategy picks feature ids, it defaults to DefaultID(IDAttribute)
+	IDStrategy IDStrategy
+	// IDPrefix is prepended to feature ids, making them strings. it can't be used with IDTypeNumber.
+	IDPrefix string
+	// IDType forces feature ids to strings or numbers
+	IDType IDType
+	// DuplicateIDs decides what happens to features sharing an id
+	DuplicateIDs DuplicateIDPolicy
+	// InvalidFeatures decides what happens to features that fail to convert: the conversion fails by
+	// default, or the feature is skipped or given a null geometry with a warning in the report
+	InvalidFeatures InvalidFeaturePolicy
+
+	// UseAliases keys properties by field alias instead of field name
+	UseAliases bool
+	// AliasCollision decides what happens when two fields share an alias
+	AliasCollision AliasCollision
+	// CoerceTypes converts attribute values to the type of their field in the schema
+	CoerceTypes bool
+	// FieldMapping renames, drops, casts, defaults and maps attribute values
+	FieldMapping *FieldMapping
+	// Include keeps only attributes whose field name matches one of these glob patterns
+	Include []string
+	// Exclude drops attributes whose field name matches one of these glob patterns
+	Exclude []string
+	// DropSystemFields drops esri system and editor tracking fields such as Shape.area and GlobalID
+	DropSystemFields bool
+
+	// Where keeps only features whose attributes match an arcgis sql where clause
+	Where string
+	// BBox keeps only features whose geometry intersects the bounding box
+	BBox *orb.Bound
+	// Intersects keeps only features whose geometry intersects the area of a bound, polygon or multipolygon
+	Intersects orb.Geometry
+
+	// MakeValid repairs geometries so the output only holds valid simple features geometries, recording
+	// the repairs in the report
+	MakeValid bool
+	// SplitAntimeridian splits lines and polygons crossing the antimeridian into multilinestrings and
+	// multipolygons, as RFC 7946 asks
+	SplitAntimeridian bool
+	// Clip cuts geometries to the area of a bound, polygon or multipolygon. features with nothing left
+	// inside it are dropped.
+	Clip orb.Geometry
+	// ClipProperty is the property set to true on features that lost part of their geometry to clipping,
+	// it defaults to DefaultClipProperty
+	ClipProperty string
+
+	// Simplify simplifies lines and rings with this method
+	Simplify SimplifyMethod
+	// SimplifyTolerance is the simplification tolerance in degrees
+	SimplifyTolerance float64
+
+	// Precision rounds coordinates to this many decimal places, when it is greater than zero. it can be at
+	// most 15. rounding moves vertices, which can create spikes and self-intersections in polygons unless
+	// MakeValid is set.
+	Precision int
+	// PrecisionMeters rounds coordinates to the fewest decimal places that keep this accuracy in meters,
+	// when it is greater than zero and Precision isn't set
+	PrecisionMeters float64
+
+	// EnvelopesAsBBox keeps xmin/ymin/xmax/ymax envelopes as a bbox member with a null geometry
+	// instead of turning them into polygons
+	EnvelopesAsBBox bool
+	// WriteBBox writes a bbox member on each feature and on the feature collection
+	WriteBBox bool
+
+	// Compute adds properties derived from expressions over the attributes and geometry
+	Compute []ComputedProperty
+
+	// Progress, when set, is called as the input is read and before each feature is converted
+	Progress func(Progress)
+}
+
+// converter holds the options and the per-collection state derived from the input schema
+type converter struct {
+	opts       Options
+	arcgisJSON *ArcGISJSON
+
+	// property keys by attribute name
+	keys map[string]string
+	// field types by attribute name
+	fieldTypes map[string]string
+	// included attributes by attribute name
+	included map[string]bool
+	// parsed where clause
+	where      *Where
+	idStrategy IDStrategy
+	// whether an invalid feature was skipped or lost its geometry
+	invalid bool
+
+	report *Report
+}
+
+func newConverter(arcgisJSON *ArcGISJSON, opts Options) (*converter, error) {
+	c := &converter{
+		opts:       opts,
+		arcgisJSON: arcgisJSON,
+		fieldTypes: map[string]string{},
+		included:   map[string]bool{},
+		idStrategy: opts.IDStrategy,
+		report:     &Report{},
+	}
+	if c.idStrategy == nil {
+		c.idStrategy = DefaultID(opts.IDAttribute)
+	}
+	if opts.IDPrefix != "" && opts.IDType == IDTypeNumber {
+		return nil, fmt.Errorf("error: an id prefix makes ids strings, it can't be used with a number id type")
+	}
+	if opts.Precision < 0 || opts.Precision > maxPrecision {
+		return nil, fmt.Errorf("error: precision must be between 0 and %d decimal places, got %d", maxPrecision, opts.Precision)
+	}
+	for _, patterns := range [][]string{opts.Include, opts.Exclude} {
+		if err := ValidatePatterns(patterns); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range arcgisJSON.Fields {
+		c.fieldTypes[field.Name] = field.Type
+	}
+	if opts.Where != "" {
+		where, err := ParseWhere(opts.Where)
+		if err != nil {
+			return nil, err
+		}
+		c.where = where
+	}
+	if opts.UseAliases {
+		keys, err := aliasKeys(arcgisJSON, opts.AliasCollision)
+		if err != nil {
+			return nil, err
+		}
+		c.keys = keys
+	}
+	if opts.FieldMapping != nil {
+		if err := opts.FieldMapping.checkKeys(c.fieldNames(), c.propertyKey); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// fieldNames returns the included field names of the schema and of every feature's attributes
+func (c *converter) fieldNames() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	add := func(name string) {
+		if !seen[name] && c.includeField(name) {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, field := range c.arcgisJSON.Fields {
+		add(field.Name)
+	}
+	for _, f := range c.arcgisJSON.Features {
+		for name := range f.Attributes {
+			add(name)
+		}
+	}
+	return names
+}
+
+// propertyKey returns the output property key for an attribute name
+func (c *converter) propertyKey(name string) string {
+	if key, ok := c.keys[name]; ok {
+		return key
+	}
+	return name
+}
+
+// properties builds the properties of a feature from its attributes
+func (c *converter) properties(attributes map[string]interface{}) map[string]interface{} {
+	props := make(map[string]interface{})
+	m := c.opts.FieldMapping
+	for k, v := range attributes {
+		if !c.includeField(k) {
+			continue
+		}
+		if c.opts.CoerceTypes {
+			v = coerce(v, c.fieldTypes[k])
+		}
+		key := c.propertyKey(k)
+		if m != nil {
+			var ok bool
+			if key, v, ok = m.apply(k, key, c.fieldTypes[k], v); !ok {
+				continue
+			}
+		}
+		props[key] = v
+	}
+	if m != nil {
+		m.applyDefaults(attributes, props, c.propertyKey, c.fieldTypes)
+	}
+	return props
+}
+
+// keepFeature reports whether a feature passes the attribute filters
+func (c *converter) keepFeature(f ArcGISFeature) bool {
+	return c.where == nil || c.where.Match(f.Attributes)
+}
+
+// keepGeometry reports whether a converted feature passes the spatial filters. features without a
+// geometry are tested by their bbox, if they have one.
+func (c *converter) keepGeometry(feature *geojson.Feature) bool {
+	if c.opts.BBox == nil && c.opts.Intersects == nil {
+		return true
+	}
+	g := feature.Geometry
+	if g == nil && len(feature.BBox) == 4 {
+		g = bboxGeometry(feature.BBox)
+	}
+	if c.opts.BBox != nil && !Intersects(g, *c.opts.BBox) {
+		return false
+	}
+	if c.opts.Intersects != nil && !Intersects(g, c.opts.Intersects) {
+		return false
+	}
+	return true
+}
+
+// transformGeometry applies the geometry options to a converted geometry. it returns nil when nothing
+// of the geometry is left.
+func (c *converter) transformGeometry(g orb.Geometry, index int) orb.Geometry {
+	changed := false
+	if c.opts.Simplify != SimplifyNone {
+		g = c.simplifyGeometry(g)
+		changed = true
+	}
+	if digits := c.precision(); digits > 0 {
+		g = c.roundGeometry(g, digits, index)
+		changed = true
+	}
+	if changed && g != nil && c.opts.MakeValid {
+		// simplifying and rounding can make rings cross again
+		g = c.makeValid(g, index)
+	}
+	return g
+}
@@ -1,7 +1,8 @@
 package arcgis2geojson
 
 import (
-	"errors"
+	"sort"
+	"strings"
 )
 
 type Ring [][]float64
@@ -12,6 +13,9 @@ type Polygon []Ring
 
 // checks if 2 x,y points are equal
 func pointsEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
 	for i := 0; i < len(a); i++ {
 		if a[i] != b[i] {
 			return false
@@ -22,12 +26,25 @@ func pointsEqual(a, b []float64) bool {
 
 // checks if the first and last points of a ring are equal and closes the ring
 func closeRing(coordinates [][]float64) [][]float64 {
+	if len(coordinates) == 0 {
+		return coordinates
+	}
 	if !pointsEqual(coordinates[0], coordinates[len(coordinates)-1]) {
 		coordinates = append(coordinates, coordinates[0])
 	}
 	return coordinates
 }
 
+// checks that every point of a ring has at least an x and a y
+func ringHasXY(ring [][]float64) bool {
+	for _, pt := range ring {
+		if len(pt) < 2 {
+			return false
+		}
+	}
+	return true
+}
+
 func reverse(ring [][]float64) [][]float64 {
 	newRing := make([][]float64, len(ring))
 	copy(newRing, ring)
@@ -44,6 +61,9 @@ func reverse(ring [][]float64) [][]float64 {
 func ringIsClockwise(ringToTest [][]float64) bool {
 	total := 0.0
 	rLength := len(ringToTest)
+	if rLength == 0 {
+		return false
+	}
 	pt1 := ringToTest[0]
 	var pt2 []float64
 	for i := 0; i < (rLength - 1); i++ {
@@ -112,16 +132,36 @@ func coordinatesContainCoordinates(outer, inner [][]float64) bool {
 // used for checking for holes in arcgis rings
 // ported from terraformer-arcgis-parser.js https://github.com/Esri/terraformer-arcgis-parser/blob/master/terraformer-arcgis-parser.js#L117-L172
 func convertRingsToGeoJSON(rings []Ring) []Polygon {
+	polygons, _ := convertRings(rings)
+	return polygons
+}
+
+// ringNotes records what convertRings did with rings it couldn't use as given, by ring index
+type ringNotes struct {
+	// dropped are rings with fewer than 4 points once closed, or points without an x and y
+	dropped []int
+	// crossing are holes outside every outer ring, added to an outer ring they cross
+	crossing []int
+	// promoted are holes outside every outer ring and crossing none, turned into outer rings
+	promoted []int
+}
 
+// convertRings is convertRingsToGeoJSON, also noting the rings it dropped or moved
+func convertRings(rings []Ring) ([]Polygon, ringNotes) {
+
+	notes := ringNotes{}
 	polygons := []Polygon{}
 	holes := []Ring{}
+	// input index of each hole
+	holeIndexes := []int{}
 
 	// for each ring
 	for r := 0; r < len(rings); r++ {
 		ring := make(Ring, len(rings[r]))
 		copy(ring, rings[r])
 		ring = closeRing(ring)
-		if len(ring) < 4 {
+		if len(ring) < 4 || !ringHasXY(ring) {
+			notes.dropped = append(notes.dropped, r)
 			continue
 		}
 		// is this ring an outer ring? is it clockwise?
@@ -132,16 +172,20 @@ func convertRingsToGeoJSON(rings []Ring) []Polygon {
 			polygons = append(polygons, polygon) // push to outer rings
 		} else {
 			holes = append(holes, reverse(ring)) // wind inner rings clockwise for RFC 7946 compliance
+			holeIndexes = append(holeIndexes, r)
 		}
 	}
 
 	uncontainedHoles := []Ring{}
+	uncontainedIndexes := []int{}
 
 	// while there are holes left...
 	var hole Ring
+	var holeIndex int
 	for len(holes) > 0 {
 		// pop a hole off out stack
 		hole, holes = holes[len(holes)-1], holes[:len(holes)-1]
+		holeIndex, holeIndexes = holeIndexes[len(holeIndexes)-1], holeIndexes[:len(holeIndexes)-1]
 
 		// loop over all outer rings and see if they contain our hole.
 		contained := false
@@ -150,7 +194,7 @@ func convertRingsToGeoJSON(rings []Ring) []Polygon {
 			outerRing := polygon[0]
 			if coordinatesContainCoordinates(outerRing, hole) {
 				// the hole is contained push it into our polygon
-				polygon = append(polygon, hole)
+				polygons[x] = append(polygon, hole)
 				contained = true
 				break
 			}
@@ -160,6 +204,7 @@ func convertRingsToGeoJSON(rings []Ring) []Polygon {
 		// sometimes this happens https://github.com/Esri/esri-leaflet/issues/320
 		if !contained {
 			uncontainedHoles = append(uncontainedHoles, hole)
+			uncontainedIndexes = append(uncontainedIndexes, holeIndex)
 		}
 	}
 
@@ -168,6 +213,7 @@ func convertRingsToGeoJSON(rings []Ring) []Polygon {
 
 		// pop a hole off out stack
 		hole, uncontainedHoles = uncontainedHoles[len(uncontainedHoles)-1], uncontainedHoles[:len(uncontainedHoles)-1]
+		holeIndex, uncontainedIndexes = uncontainedIndexes[len(uncontainedIndexes)-1], uncontainedIndexes[:len(uncontainedIndexes)-1]
 
 		// loop over all outer rings and see if any intersect our hole.
 		intersects := false
@@ -177,8 +223,9 @@ func convertRingsToGeoJSON(rings []Ring) []Polygon {
 			outerRing := polygon[0]
 			if arrayIntersectsArray(outerRing, hole) {
 				// the hole is contained push it into our polygon
-				polygon = append(polygon, hole)
+				polygons[x] = append(polygon, hole)
 				intersects = true
+				notes.crossing = append(notes.crossing, holeIndex)
 				break
 			}
 		}
@@ -187,10 +234,13 @@ func convertRingsToGeoJSON(rings []Ring) []Polygon {
 			polygon := Polygon{}
 			polygon = append(polygon, Ring(reverse(hole)))
 			polygons = append(polygons, polygon)
+			notes.promoted = append(notes.promoted, holeIndex)
 		}
 	}
 
-	return polygons
+	sort.Ints(notes.crossing)
+	sort.Ints(notes.promoted)
+	return polygons, notes
 }
 
 // This function ensures that rings are oriented in the right directions
@@ -245,20 +295,21 @@ func flattenMultiPolygonRings(rings [][][][]float64) [][][]float64 {
 	return output
 }
 
+// finds the value of the id attribute, preferring an exact match of its name over a case-insensitive one.
+// when several attributes match case-insensitively, the first in sorted order is used.
 func getId(attributes map[string]interface{}, idAttribute string) (interface{}, error) {
-	for k, v := range attributes {
-		if k == idAttribute {
-			return v, nil
-		}
+	if v, ok := attributes[idAttribute]; ok {
+		return v, nil
 	}
-	keys := []string{"OBJECTID", "FID"}
-	for _, key := range keys {
-		for k, v := range attributes {
-			if k == key {
-				return v, nil
-			}
+	matches := []string{}
+	for k := range attributes {
+		if strings.EqualFold(k, idAttribute) {
+			matches = append(matches, k)
 		}
-
 	}
-	return nil, errors.New("no valid id attribute found")
+	if len(matches) == 0 {
+		return nil, errNoID
+	}
+	sort.Strings(matches)
+	return attributes[matches[0]], nil
 }
@@ -0,0 +1,124 @@
+package arcgis2geojson
+
+import (
+	"math"
+
+	"github.com/paulmach/orb"
+)
+
+// maxPrecision is the most decimal places coordinates are rounded to. a float64 holds about 15
+// significant digits, and 10^digits overflows well before 400.
+const maxPrecision = 15
+
+// metersPerDegree is the length of a degree of latitude, and of longitude at the equator
+const metersPerDegree = 111320.0
+
+// PrecisionForMeters returns the fewest decimal places of a degree that keep coordinates accurate to
+// the given number of meters
+func PrecisionForMeters(meters float64) int {
+	if meters <= 0 {
+		return 0
+	}
+	digits := int(math.Ceil(math.Log10(metersPerDegree / meters)))
+	if digits < 0 {
+		return 0
+	}
+	return digits
+}
+
+// precision returns the decimal places coordinates are rounded to, or 0 for no rounding
+func (c *converter) precision() int {
+	digits := c.opts.Precision
+	if digits <= 0 {
+		digits = PrecisionForMeters(c.opts.PrecisionMeters)
+	}
+	if digits > maxPrecision {
+		return maxPrecision
+	}
+	return digits
+}
+
+// roundGeometry rounds coordinates to digits decimal places and collapses consecutive vertices that
+// became identical. lines left with fewer than 2 vertices and rings left with fewer than 4 are dropped
+// rather than written invalid.
+func (c *converter) roundGeometry(g orb.Geometry, digits int, index int) orb.Geometry {
+	factor := math.Pow(10, float64(digits))
+	round := func(p orb.Point) orb.Point {
+		return orb.Point{math.Round(p[0]*factor) / factor, math.Round(p[1]*factor) / factor}
+	}
+	roundPath := func(points []orb.Point) []orb.Point {
+		path := make([]orb.Point, 0, len(points))
+		for _, p := range points {
+			p = round(p)
+			if len(path) == 0 || !path[len(path)-1].Equal(p) {
+				path = append(path, p)
+			}
+		}
+		return path
+	}
+	roundPolygon := func(polygon orb.Polygon) orb.Polygon {
+		rounded := orb.Polygon{}
+		for i, r := range polygon {
+			ring := orb.Ring(roundPath(r))
+			if len(ring) < 4 {
+				if i == 0 {
+					c.warn(index, "polygon collapsed after rounding to %d decimal places", digits)
+					return nil
+				}
+				c.warn(index, "hole collapsed after rounding to %d decimal places", digits)
+				continue
+			}
+			rounded = append(rounded, ring)
+		}
+		return rounded
+	}
+
+	switch t := g.(type) {
+	case orb.Point:
+		return round(t)
+	case orb.MultiPoint:
+		mp := make(orb.MultiPoint, len(t))
+		for i, p := range t {
+			mp[i] = round(p)
+		}
+		return mp
+	case orb.LineString:
+		ls := orb.LineString(roundPath(t))
+		if len(ls) < 2 {
+			c.warn(index, "line collapsed after rounding to %d decimal places", digits)
+			return nil
+		}
+		return ls
+	case orb.MultiLineString:
+		mls := orb.MultiLineString{}
+		for _, l := range t {
+			ls := orb.LineString(roundPath(l))
+			if len(ls) < 2 {
+				c.warn(index, "line collapsed after rounding to %d decimal places", digits)
+				continue
+			}
+			mls = append(mls, ls)
+		}
+		if len(mls) == 0 {
+			return nil
+		}
+		return mls
+	case orb.Polygon:
+		if p := roundPolygon(t); p != nil {
+			return p
+		}
+		return nil
+	case orb.MultiPolygon:
+		mp := orb.MultiPolygon{}
+		for _, polygon := range t {
+			if p := roundPolygon(polygon); p != nil {
+				mp = append(mp, p)
+			}
+		}
+		if len(mp) == 0 {
+			return nil
+		}
+		return mp
+	}
+	return g
+}
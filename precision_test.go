@@ -0,0 +1,64 @@
+package arcgis2geojson
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrecisionForMeters(t *testing.T) {
+	tests := map[float64]int{0: 0, 1: 6, 0.1: 7, 10: 5, 1000: 3, 1e6: 0}
+	for meters, expected := range tests {
+		if digits := PrecisionForMeters(meters); digits != expected {
+			t.Errorf("%v meters: expected %d digits, got %d", meters, expected, digits)
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"paths": [[[-122.30211399999998, 47.1], [-122.302114001, 47.1], [-122.4, 47.2]]]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"paths": [[[0, 0], [0.0001, 0]], [[0, 0], [10, 10]]]}},
+			{"attributes": {"OBJECTID": 3}, "geometry": {"rings": [[[5, 5], [5, 5.0001], [5.0001, 5.0001], [5, 5]]]}}
+		]
+	}`
+	b, report, err := ConvertWithReport([]byte(data), Options{Precision: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "99998") {
+		t.Errorf("expected rounded coordinates, got %s", b)
+	}
+	raw := convertRawFeatureCollection(t, data, Options{Precision: 2})
+	if string(raw.Features[0].Geometry) != `{"type":"LineString","coordinates":[[-122.3,47.1],[-122.4,47.2]]}` {
+		t.Errorf("expected collapsed line, got %s", raw.Features[0].Geometry)
+	}
+	if string(raw.Features[1].Geometry) != `{"type":"MultiLineString","coordinates":[[[0,0],[10,10]]]}` {
+		t.Errorf("expected the collapsed line to be dropped, got %s", raw.Features[1].Geometry)
+	}
+	if string(raw.Features[2].Geometry) != "null" {
+		t.Errorf("expected the collapsed polygon to be dropped, got %s", raw.Features[2].Geometry)
+	}
+	if len(report.Warnings) != 2 {
+		t.Errorf("expected 2 collapse warnings, got %v", report.Warnings)
+	}
+
+	raw = convertRawFeatureCollection(t, data, Options{PrecisionMeters: 1000})
+	if !strings.HasPrefix(string(raw.Features[0].Geometry), `{"type":"LineString","coordinates":[[-122.302,47.1],`) {
+		t.Errorf("expected 3 decimal places, got %s", raw.Features[0].Geometry)
+	}
+}
+
+func TestPrecisionLimits(t *testing.T) {
+	data := `{"spatialReference": {"wkid": 4326}, "features": [{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1.5, 2.25]]}}]}`
+	for _, precision := range []int{-1, 16, 400} {
+		if _, err := ConvertWithOptions([]byte(data), Options{Precision: precision}); err == nil {
+			t.Errorf("precision %d: expected an error", precision)
+		}
+	}
+	raw := convertRawFeatureCollection(t, data, Options{PrecisionMeters: 1e-300})
+	if string(raw.Features[0].Geometry) != `{"type":"Point","coordinates":[1.5,2.25]}` {
+		t.Errorf("expected coordinates unchanged at the largest precision, got %s", raw.Features[0].Geometry)
+	}
+}
@@ -0,0 +1,80 @@
+package arcgis2geojson
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// Progress is how far a conversion has got
+type Progress struct {
+	// Features counts the input features processed so far
+	Features int
+	// TotalFeatures counts the input features, it is 0 while the input is being read
+	TotalFeatures int
+	// BytesRead counts the bytes of input read so far
+	BytesRead int64
+	// TotalBytes is the size of the input, or 0 when it isn't known
+	TotalBytes int64
+}
+
+// ConvertContext converts arcgis json to geojson like ConvertWithReport, stopping with an error wrapping
+// ctx.Err() when ctx is done before every feature is converted
+func ConvertContext(ctx context.Context, data []byte, opts Options) ([]byte, *Report, error) {
+	size := int64(len(data))
+	return convert(ctx, data, opts, Progress{BytesRead: size, TotalBytes: size})
+}
+
+// ConvertFeatures converts arcgis json like ConvertContext, but returns the converted features with the
+// index in the input of each instead of a feature collection. duplicate ids are left for the caller to
+// resolve, there is no collection bbox and the output statistics are left for the caller to count with
+// Stats.AddOutputFeature, so that features converted a page at a time can be written as one collection.
+func ConvertFeatures(ctx context.Context, data []byte, opts Options) ([]*geojson.Feature, []int, *Report, error) {
+	start := time.Now()
+	size := int64(len(data))
+	c, features, indexes, err := convertFeatures(ctx, data, opts, Progress{BytesRead: size, TotalBytes: size})
+	if c == nil {
+		return nil, nil, nil, err
+	}
+	c.report.Stats.OutputGeometryTypes = map[string]int{}
+	c.report.Stats.Elapsed = time.Since(start)
+	if err != nil {
+		return nil, nil, c.report, err
+	}
+	return features, indexes, c.report, nil
+}
+
+// ConvertReaderContext reads arcgis json from r and converts it like ConvertContext. size is the length
+// of the input reported as Progress.TotalBytes, or 0 when it isn't known.
+func ConvertReaderContext(ctx context.Context, r io.Reader, size int64, opts Options) ([]byte, *Report, error) {
+	pr := &progressReader{ctx: ctx, r: r, progress: Progress{TotalBytes: size}, report: opts.Progress}
+	data, err := ioutil.ReadAll(pr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return convert(ctx, data, opts, pr.progress)
+}
+
+// progressReader reports the bytes read from r, and stops reading when ctx is done
+type progressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	progress Progress
+	report   func(Progress)
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	if err := pr.ctx.Err(); err != nil {
+		return 0, fmt.Errorf("error: conversion cancelled after reading %d bytes: %w", pr.progress.BytesRead, err)
+	}
+	n, err := pr.r.Read(p)
+	pr.progress.BytesRead += int64(n)
+	if n > 0 && pr.report != nil {
+		pr.report(pr.progress)
+	}
+	return n, err
+}
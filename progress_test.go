@@ -0,0 +1,78 @@
+package arcgis2geojson
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const progressData = `{"spatialReference": {"wkid": 4326}, "features": [
+	{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1, 2]]}},
+	{"attributes": {"OBJECTID": 2}, "geometry": {"points": [[3, 4]]}},
+	{"attributes": {"OBJECTID": 3}, "geometry": {"points": [[5, 6]]}}
+]}`
+
+func TestConvertContextProgress(t *testing.T) {
+	features := []int{}
+	opts := Options{Progress: func(p Progress) {
+		if p.TotalFeatures != 3 || p.BytesRead != int64(len(progressData)) || p.TotalBytes != int64(len(progressData)) {
+			t.Errorf("unexpected progress %+v", p)
+		}
+		features = append(features, p.Features)
+	}}
+	if _, _, err := ConvertContext(context.Background(), []byte(progressData), opts); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{0, 1, 2, 3}; len(features) != len(expected) || features[3] != 3 {
+		t.Errorf("expected progress %v, got %v", expected, features)
+	}
+}
+
+func TestConvertContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := Options{Progress: func(p Progress) {
+		if p.Features == 2 {
+			cancel()
+		}
+	}}
+	_, _, err := ConvertContext(ctx, []byte(progressData), opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the conversion to be cancelled, got %v", err)
+	}
+	if expected := "error: conversion cancelled after 2 features: context canceled"; err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err)
+	}
+
+	// cancelled while reading
+	_, _, err = ConvertReaderContext(ctx, strings.NewReader(progressData), 0, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected reading to be cancelled, got %v", err)
+	}
+}
+
+func TestConvertReaderContext(t *testing.T) {
+	var last Progress
+	reads := 0
+	opts := Options{Progress: func(p Progress) {
+		if p.TotalFeatures == 0 {
+			reads++
+		}
+		last = p
+	}}
+	b, _, err := ConvertReaderContext(context.Background(), strings.NewReader(progressData), int64(len(progressData)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := ConvertWithOptions([]byte(progressData), Options{})
+	if string(b) != string(expected) {
+		t.Errorf("expected %s, got %s", expected, b)
+	}
+	if reads == 0 {
+		t.Error("expected progress while reading")
+	}
+	if last.Features != 3 || last.BytesRead != int64(len(progressData)) || last.TotalBytes != int64(len(progressData)) {
+		t.Errorf("unexpected final progress %+v", last)
+	}
+}
@@ -0,0 +1,45 @@
+package arcgis2geojson
+
+import "fmt"
+
+// Report describes what happened during a conversion
+type Report struct {
+	// DuplicateIDs lists the ids shared by more than one feature
+	DuplicateIDs []DuplicateID `json:"duplicateIds,omitempty"`
+	// Warnings are problems that didn't stop the conversion
+	Warnings []Warning `json:"warnings,omitempty"`
+	// Repairs are the geometry problems fixed by MakeValid
+	Repairs []Repair `json:"repairs,omitempty"`
+	// Stats summarizes the conversion
+	Stats Stats `json:"stats"`
+}
+
+// Warning is a problem that didn't stop the conversion
+type Warning struct {
+	// Feature is the index in the input of the feature the warning is about, or -1 for the whole collection
+	Feature int    `json:"feature"`
+	Message string `json:"message"`
+}
+
+// Repair is a kind of geometry problem fixed in a feature
+type Repair struct {
+	// Feature is the index in the input of the repaired feature
+	Feature int `json:"feature"`
+	// Kind is the kind of issue repaired, as reported by Validate, or ring-orientation
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
+
+// DuplicateID is an id shared by more than one feature
+type DuplicateID struct {
+	ID interface{} `json:"id"`
+	// Features are the indexes in the input of the features sharing the id
+	Features []int `json:"features"`
+	// Resolution is what was done about it: kept, kept first, kept last or rewritten
+	Resolution string `json:"resolution"`
+}
+
+// warn adds a warning about a feature, or the collection when index is -1, to the report
+func (c *converter) warn(index int, format string, args ...interface{}) {
+	c.report.Warnings = append(c.report.Warnings, Warning{Feature: index, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,90 @@
+package arcgis2geojson
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/simplify"
+)
+
+// SimplifyMethod is a line simplification algorithm
+type SimplifyMethod int
+
+const (
+	// SimplifyNone keeps every vertex
+	SimplifyNone SimplifyMethod = iota
+	// SimplifyDouglasPeucker drops vertices closer than the tolerance to the simplified line
+	SimplifyDouglasPeucker
+	// SimplifyVisvalingam drops vertices whose triangle with their neighbours has an area below the
+	// tolerance squared
+	SimplifyVisvalingam
+)
+
+// ParseSimplifyMethod parses a simplification method name (none, douglas-peucker or visvalingam)
+func ParseSimplifyMethod(s string) (SimplifyMethod, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return SimplifyNone, nil
+	case "douglas-peucker", "douglaspeucker", "dp":
+		return SimplifyDouglasPeucker, nil
+	case "visvalingam", "vw":
+		return SimplifyVisvalingam, nil
+	}
+	return 0, fmt.Errorf("error: unknown simplification method %q", s)
+}
+
+// minRingPoints is the fewest points of a valid closed ring
+const minRingPoints = 4
+
+// simplifyGeometry simplifies lines and rings with the configured method and tolerance. rings are never
+// simplified below four points, so polygons keep their shells and holes.
+func (c *converter) simplifyGeometry(g orb.Geometry) orb.Geometry {
+	tolerance := c.opts.SimplifyTolerance
+	var lines, rings interface {
+		LineString(orb.LineString) orb.LineString
+		Ring(orb.Ring) orb.Ring
+	}
+	switch c.opts.Simplify {
+	case SimplifyDouglasPeucker:
+		lines = simplify.DouglasPeucker(tolerance)
+		rings = lines
+	case SimplifyVisvalingam:
+		lines = simplify.VisvalingamThreshold(tolerance * tolerance)
+		rings = simplify.Visvalingam(tolerance*tolerance, minRingPoints)
+	default:
+		return g
+	}
+	simplifyRing := func(r orb.Ring) orb.Ring {
+		simplified := rings.Ring(r.Clone())
+		if len(simplified) < minRingPoints {
+			// keep the largest triangle rather than collapsing the ring
+			return simplify.VisvalingamKeep(minRingPoints).Ring(r)
+		}
+		return simplified
+	}
+	simplifyPolygon := func(p orb.Polygon) orb.Polygon {
+		for i := range p {
+			p[i] = simplifyRing(p[i])
+		}
+		return p
+	}
+
+	switch t := g.(type) {
+	case orb.LineString:
+		return lines.LineString(t)
+	case orb.MultiLineString:
+		for i := range t {
+			t[i] = lines.LineString(t[i])
+		}
+		return t
+	case orb.Polygon:
+		return simplifyPolygon(t)
+	case orb.MultiPolygon:
+		for i := range t {
+			t[i] = simplifyPolygon(t[i])
+		}
+		return t
+	}
+	return g
+}
@@ -0,0 +1,28 @@
+package arcgis2geojson
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestSimplify(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"paths": [[[0, 0], [1, 0.01], [2, -0.01], [3, 0], [4, 5]]]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"rings": [[[0, 0], [0, 0.1], [0.1, 0.1], [0.1, 0], [0, 0]]]}}
+		]
+	}`
+	for _, method := range []SimplifyMethod{SimplifyDouglasPeucker, SimplifyVisvalingam} {
+		fc := convertFeatureCollection(t, data, Options{Simplify: method, SimplifyTolerance: 0.5})
+		ls := fc.Features[0].Geometry.(orb.LineString)
+		if len(ls) != 3 || ls[0] != (orb.Point{0, 0}) || ls[2] != (orb.Point{4, 5}) {
+			t.Errorf("method %d: expected the line simplified to 3 points, got %v", method, ls)
+		}
+		p := fc.Features[1].Geometry.(orb.Polygon)
+		if len(p[0]) != 4 {
+			t.Errorf("method %d: expected the ring to keep 4 points, got %v", method, p[0])
+		}
+	}
+}
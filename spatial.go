@@ -0,0 +1,242 @@
+package arcgis2geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/paulmach/orb"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// Intersects reports whether geometry g intersects the area covered by filter, which may be an
+// orb.Bound, orb.Polygon or orb.MultiPolygon. it uses the point in polygon and segment intersection
+// tests ported in port.go.
+func Intersects(g orb.Geometry, filter orb.Geometry) bool {
+	if g == nil || filter == nil {
+		return false
+	}
+	for _, polygon := range filterPolygons(filter) {
+		if intersectsPolygon(g, polygon) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPolygons returns the polygons of a spatial filter
+func filterPolygons(filter orb.Geometry) []orb.Polygon {
+	switch f := filter.(type) {
+	case orb.Bound:
+		return []orb.Polygon{f.ToPolygon()}
+	case orb.Polygon:
+		return []orb.Polygon{f}
+	case orb.MultiPolygon:
+		return f
+	case orb.Collection:
+		polygons := []orb.Polygon{}
+		for _, g := range f {
+			polygons = append(polygons, filterPolygons(g)...)
+		}
+		return polygons
+	}
+	return nil
+}
+
+func intersectsPolygon(g orb.Geometry, polygon orb.Polygon) bool {
+	if len(polygon) == 0 || len(polygon[0]) == 0 || !g.Bound().Intersects(polygon.Bound()) {
+		return false
+	}
+	// a vertex of the geometry is inside the polygon or on its boundary
+	for _, pt := range geometryVertices(g) {
+		if polygonCoversPoint(polygon, pt) {
+			return true
+		}
+	}
+	// an edge of the geometry crosses or touches an edge of the polygon
+	for _, path := range geometryPaths(g) {
+		for _, ring := range polygon {
+			if pathsIntersect(path, toCoordinates(ring)) {
+				return true
+			}
+		}
+	}
+	// the polygon is inside the geometry
+	pt := []float64{polygon[0][0][0], polygon[0][0][1]}
+	for _, p := range geometryPolygons(g) {
+		if polygonContainsPoint(p, pt) {
+			return true
+		}
+	}
+	return false
+}
+
+// polygonContainsPoint reports whether a point is inside the outer ring of a polygon and outside its holes
+func polygonContainsPoint(polygon orb.Polygon, pt []float64) bool {
+	if len(polygon) == 0 || !coordinatesContainPoint(toCoordinates(polygon[0]), pt) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if coordinatesContainPoint(toCoordinates(hole), pt) {
+			return false
+		}
+	}
+	return true
+}
+
+// polygonCoversPoint reports whether a point is inside a polygon or on the boundary of one of its rings
+func polygonCoversPoint(polygon orb.Polygon, pt []float64) bool {
+	for _, ring := range polygon {
+		if onRing(ring, orb.Point{pt[0], pt[1]}) {
+			return true
+		}
+	}
+	return polygonContainsPoint(polygon, pt)
+}
+
+// onSegment reports whether pt is collinear with the segment from a to b and within its bounds
+func onSegment(a, b, pt []float64) bool {
+	return onRing(orb.Ring{{a[0], a[1]}, {b[0], b[1]}}, orb.Point{pt[0], pt[1]})
+}
+
+// pathsIntersect is arrayIntersectsArray extended to segments that touch at an end point or overlap
+// along a shared edge, which the ported test misses
+func pathsIntersect(a, b [][]float64) bool {
+	for i := 0; i < len(a)-1; i++ {
+		for j := 0; j < len(b)-1; j++ {
+			if vertexIntersectsVertex(a[i], a[i+1], b[j], b[j+1]) ||
+				onSegment(b[j], b[j+1], a[i]) || onSegment(b[j], b[j+1], a[i+1]) ||
+				onSegment(a[i], a[i+1], b[j]) || onSegment(a[i], a[i+1], b[j+1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toCoordinates(points []orb.Point) [][]float64 {
+	coordinates := make([][]float64, len(points))
+	for i, pt := range points {
+		coordinates[i] = []float64{pt[0], pt[1]}
+	}
+	return coordinates
+}
+
+// geometryVertices returns every vertex of a geometry
+func geometryVertices(g orb.Geometry) [][]float64 {
+	switch t := g.(type) {
+	case orb.Point:
+		return [][]float64{{t[0], t[1]}}
+	case orb.MultiPoint:
+		return toCoordinates(t)
+	case orb.Collection:
+		vertices := [][]float64{}
+		for _, c := range t {
+			vertices = append(vertices, geometryVertices(c)...)
+		}
+		return vertices
+	}
+	vertices := [][]float64{}
+	for _, path := range geometryPaths(g) {
+		vertices = append(vertices, path...)
+	}
+	return vertices
+}
+
+// geometryPaths returns the line strings and rings of a geometry
+func geometryPaths(g orb.Geometry) [][][]float64 {
+	paths := [][][]float64{}
+	switch t := g.(type) {
+	case orb.LineString:
+		paths = append(paths, toCoordinates(t))
+	case orb.MultiLineString:
+		for _, ls := range t {
+			paths = append(paths, toCoordinates(ls))
+		}
+	case orb.Ring:
+		paths = append(paths, toCoordinates(t))
+	case orb.Bound:
+		paths = append(paths, toCoordinates(t.ToRing()))
+	case orb.Polygon, orb.MultiPolygon:
+		for _, p := range geometryPolygons(t) {
+			for _, r := range p {
+				paths = append(paths, toCoordinates(r))
+			}
+		}
+	case orb.Collection:
+		for _, c := range t {
+			paths = append(paths, geometryPaths(c)...)
+		}
+	}
+	return paths
+}
+
+// geometryPolygons returns the polygons of a geometry
+func geometryPolygons(g orb.Geometry) []orb.Polygon {
+	switch t := g.(type) {
+	case orb.Polygon:
+		return []orb.Polygon{t}
+	case orb.MultiPolygon:
+		return t
+	case orb.Bound:
+		return []orb.Polygon{t.ToPolygon()}
+	case orb.Collection:
+		polygons := []orb.Polygon{}
+		for _, c := range t {
+			polygons = append(polygons, geometryPolygons(c)...)
+		}
+		return polygons
+	}
+	return nil
+}
+
+// LoadPolygons reads the polygons of a geojson file, which may hold a feature collection, a feature
+// or a bare geometry
+func LoadPolygons(path string) (orb.MultiPolygon, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolygons(data)
+}
+
+// ParsePolygons parses the polygons of a geojson feature collection, feature or geometry
+func ParsePolygons(data []byte) (orb.MultiPolygon, error) {
+	object := struct {
+		Type string `json:"type"`
+	}{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	geometries := []orb.Geometry{}
+	switch object.Type {
+	case "FeatureCollection":
+		fc, err := geojson.UnmarshalFeatureCollection(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fc.Features {
+			geometries = append(geometries, f.Geometry)
+		}
+	case "Feature":
+		f, err := geojson.UnmarshalFeature(data)
+		if err != nil {
+			return nil, err
+		}
+		geometries = append(geometries, f.Geometry)
+	default:
+		g, err := geojson.UnmarshalGeometry(data)
+		if err != nil {
+			return nil, err
+		}
+		geometries = append(geometries, g.Geometry())
+	}
+	polygons := orb.MultiPolygon{}
+	for _, g := range geometries {
+		polygons = append(polygons, geometryPolygons(g)...)
+	}
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("error: no polygons found in %s geojson", object.Type)
+	}
+	return polygons, nil
+}
@@ -0,0 +1,73 @@
+package arcgis2geojson
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestIntersects(t *testing.T) {
+	// a square with a hole in the middle
+	filter := orb.Polygon{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}},
+	}
+	tests := []struct {
+		name     string
+		g        orb.Geometry
+		expected bool
+	}{
+		{"point inside", orb.Point{1, 1}, true},
+		{"point in hole", orb.Point{5, 5}, false},
+		{"point outside", orb.Point{11, 5}, false},
+		{"line crossing", orb.LineString{{-5, 5}, {15, 5}}, true},
+		{"line outside", orb.LineString{{-5, -5}, {-5, 15}}, false},
+		{"line in hole", orb.LineString{{4.5, 5}, {5.5, 5}}, false},
+		{"polygon covering", orb.Polygon{{{-1, -1}, {11, -1}, {11, 11}, {-1, 11}, {-1, -1}}}, true},
+		{"polygon in hole", orb.Polygon{{{4.5, 4.5}, {5.5, 4.5}, {5.5, 5.5}, {4.5, 5.5}, {4.5, 4.5}}}, false},
+		{"multipoint", orb.MultiPoint{{20, 20}, {9, 9}}, true},
+		{"point on edge", orb.Point{10, 5}, true},
+		{"point on vertex", orb.Point{10, 10}, true},
+		{"point on hole edge", orb.Point{4, 5}, true},
+		{"line touching vertex", orb.LineString{{10, 10}, {15, 15}}, true},
+		{"line along edge", orb.LineString{{10, 2}, {10, 8}}, true},
+		{"line ending on edge", orb.LineString{{15, 5}, {10, 5}}, true},
+		{"polygon sharing an edge", orb.Polygon{{{10, 0}, {20, 0}, {20, 10}, {10, 10}, {10, 0}}}, true},
+		{"polygon sharing part of an edge", orb.Polygon{{{10, 2}, {20, 2}, {20, 8}, {10, 8}, {10, 2}}}, true},
+		{"polygon touching a vertex", orb.Polygon{{{10, 10}, {20, 10}, {20, 20}, {10, 20}, {10, 10}}}, true},
+		{"polygon near an edge", orb.Polygon{{{10.1, 0}, {20, 0}, {20, 10}, {10.1, 10}, {10.1, 0}}}, false},
+	}
+	for _, test := range tests {
+		if Intersects(test.g, filter) != test.expected {
+			t.Errorf("%s: expected %v", test.name, test.expected)
+		}
+	}
+	if !Intersects(orb.Point{1, 1}, orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{2, 2}}) {
+		t.Error("expected point to intersect bound")
+	}
+}
+
+func TestConvertSpatialFilter(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1, 1]]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"paths": [[[5, -1], [5, 1]]]}},
+			{"attributes": {"OBJECTID": 3}, "geometry": {"rings": [[[20, 20], [20, 21], [21, 21], [21, 20], [20, 20]]]}}
+		]
+	}`
+	bbox := orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{10, 10}}
+	fc := convertFeatureCollection(t, data, Options{BBox: &bbox})
+	if len(fc.Features) != 2 {
+		t.Errorf("expected 2 features, got %d", len(fc.Features))
+	}
+
+	polygons, err := ParsePolygons([]byte(`{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [[[19, 19], [22, 19], [22, 22], [19, 22], [19, 19]]]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc = convertFeatureCollection(t, data, Options{Intersects: polygons})
+	if len(fc.Features) != 1 || fc.Features[0].ID != 3.0 {
+		t.Errorf("expected only feature 3, got %v", fc.Features)
+	}
+}
@@ -0,0 +1,151 @@
+package arcgis2geojson
+
+import (
+	"time"
+
+	"github.com/paulmach/orb"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+// Stats summarizes a conversion
+type Stats struct {
+	// InputFeatures counts the features of the input
+	InputFeatures int `json:"inputFeatures"`
+	// OutputFeatures counts the features written
+	OutputFeatures int `json:"outputFeatures"`
+	// InputGeometryTypes counts the input features that pass the filters by arcgis geometry type: point,
+	// multipoint, polyline, polygon, envelope or none
+	InputGeometryTypes map[string]int `json:"inputGeometryTypes"`
+	// OutputGeometryTypes counts the features written by geojson geometry type, or null
+	OutputGeometryTypes map[string]int `json:"outputGeometryTypes"`
+	// NullGeometries counts the features written with a null geometry
+	NullGeometries int `json:"nullGeometries"`
+	// RingsDropped counts rings of the features that pass the filters dropped for having fewer than 4
+	// points or points without an x and y
+	RingsDropped int `json:"ringsDropped"`
+	// HolesPromoted counts holes of the features that pass the filters outside every outer ring, and
+	// crossing none, turned into outer rings
+	HolesPromoted int `json:"holesPromoted"`
+	// InputVertices counts the coordinates of the input features that pass the filters
+	InputVertices int `json:"inputVertices"`
+	// OutputVertices counts the coordinates of the features written
+	OutputVertices int `json:"outputVertices"`
+	// Extent is the bbox of the geometries written, when there are any
+	Extent geojson.BBox `json:"extent,omitempty"`
+	// Elapsed is how long the conversion took
+	Elapsed time.Duration `json:"elapsedNanoseconds"`
+}
+
+// inputGeometryType returns the arcgis geometry type of a feature, picking the same geometry as
+// featureToFeature when it has more than one
+func inputGeometryType(f ArcGISFeature) string {
+	switch {
+	case f.Xmin != nil && f.Ymin != nil && f.Xmax != nil && f.Ymax != nil:
+		return "envelope"
+	case len(f.Rings) != 0 || len(f.Geometry.Rings) != 0:
+		return "polygon"
+	case len(f.Paths) != 0 || len(f.Geometry.Paths) != 0:
+		return "polyline"
+	case len(f.Points) != 0 || len(f.Geometry.Points) != 0:
+		return "multipoint"
+	case f.X != 0 && f.Y != 0:
+		return "point"
+	}
+	return "none"
+}
+
+// inputVertices counts the coordinates of a feature
+func inputVertices(f ArcGISFeature) int {
+	n := len(f.Points) + len(f.Geometry.Points)
+	for _, paths := range [][][][]float64{f.Paths, f.Geometry.Paths} {
+		for _, path := range paths {
+			n += len(path)
+		}
+	}
+	for _, rings := range [][]Ring{f.Rings, f.Geometry.Rings} {
+		for _, ring := range rings {
+			n += len(ring)
+		}
+	}
+	if f.X != 0 && f.Y != 0 {
+		n++
+	}
+	return n
+}
+
+// inputStats counts the geometry type and vertices of an input feature that passed the filters
+func (c *converter) inputStats(f ArcGISFeature) {
+	stats := &c.report.Stats
+	stats.InputGeometryTypes[inputGeometryType(f)]++
+	stats.InputVertices += inputVertices(f)
+}
+
+// outputStats counts the features, geometry types and vertices written, and finds their extent
+func (c *converter) outputStats(features []*geojson.Feature) {
+	c.report.Stats.OutputGeometryTypes = map[string]int{}
+	for _, f := range features {
+		c.report.Stats.AddOutputFeature(f)
+	}
+}
+
+// AddOutputFeature counts a written feature in the output statistics. it is for callers writing the
+// features of ConvertFeatures themselves.
+func (s *Stats) AddOutputFeature(f *geojson.Feature) {
+	s.OutputFeatures++
+	if s.OutputGeometryTypes == nil {
+		s.OutputGeometryTypes = map[string]int{}
+	}
+	if f.Geometry == nil {
+		s.OutputGeometryTypes["null"]++
+		s.NullGeometries++
+		return
+	}
+	s.OutputGeometryTypes[f.Geometry.GeoJSONType()]++
+	s.OutputVertices += countVertices(f.Geometry)
+	extent := f.Geometry.Bound()
+	if s.Extent != nil {
+		extent = extent.Union(s.Extent.Bound())
+	}
+	s.Extent = geojson.NewBBox(extent)
+}
+
+// countVertices counts the coordinates of a geometry
+func countVertices(g orb.Geometry) int {
+	switch t := g.(type) {
+	case orb.Point:
+		return 1
+	case orb.MultiPoint:
+		return len(t)
+	case orb.LineString:
+		return len(t)
+	case orb.Ring:
+		return len(t)
+	case orb.MultiLineString:
+		n := 0
+		for _, ls := range t {
+			n += len(ls)
+		}
+		return n
+	case orb.Polygon:
+		n := 0
+		for _, r := range t {
+			n += len(r)
+		}
+		return n
+	case orb.MultiPolygon:
+		n := 0
+		for _, p := range t {
+			n += countVertices(p)
+		}
+		return n
+	case orb.Collection:
+		n := 0
+		for _, g := range t {
+			n += countVertices(g)
+		}
+		return n
+	case orb.Bound:
+		return 5
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package arcgis2geojson
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+	geojson "github.com/paulmach/orb/geojson"
+)
+
+const statsData = `{"spatialReference": {"wkid": 4326}, "features": [
+		{"attributes": {"OBJECTID": 1}, "geometry": {"points": [[1, 2]]}},
+		{"attributes": {"OBJECTID": 2}, "geometry": {"paths": [[[0, 0], [5, 5], [10, 10]]]}},
+		{"attributes": {"OBJECTID": 3}, "geometry": {"rings": [
+			[[0, 0], [0, 10], [10, 10], [10, 0], [0, 0]],
+			[[0, 0], [1, 1]],
+			[[20, 20], [21, 20], [21, 21], [20, 21], [20, 20]]
+		]}},
+		{"attributes": {"OBJECTID": 4}}
+	]}`
+
+func TestStats(t *testing.T) {
+	_, report, err := ConvertWithReport([]byte(statsData), Options{Simplify: SimplifyDouglasPeucker, SimplifyTolerance: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := report.Stats
+	if stats.InputFeatures != 4 || stats.OutputFeatures != 4 {
+		t.Errorf("expected 4 features in and out, got %d and %d", stats.InputFeatures, stats.OutputFeatures)
+	}
+	expected := map[string]int{"multipoint": 1, "polyline": 1, "polygon": 1, "none": 1}
+	if !reflect.DeepEqual(stats.InputGeometryTypes, expected) {
+		t.Errorf("expected input types %v, got %v", expected, stats.InputGeometryTypes)
+	}
+	expected = map[string]int{"Point": 1, "LineString": 1, "MultiPolygon": 1, "null": 1}
+	if !reflect.DeepEqual(stats.OutputGeometryTypes, expected) {
+		t.Errorf("expected output types %v, got %v", expected, stats.OutputGeometryTypes)
+	}
+	if stats.NullGeometries != 1 || stats.RingsDropped != 1 || stats.HolesPromoted != 1 {
+		t.Errorf("expected 1 null geometry, dropped ring and promoted hole, got %+v", stats)
+	}
+	// the middle point of the line is simplified away
+	if stats.InputVertices != 16 || stats.OutputVertices != 13 {
+		t.Errorf("expected 16 vertices in and 13 out, got %d and %d", stats.InputVertices, stats.OutputVertices)
+	}
+	if !reflect.DeepEqual(stats.Extent, geojson.BBox{0, 0, 21, 21}) {
+		t.Errorf("expected extent [0 0 21 21], got %v", stats.Extent)
+	}
+}
+
+func TestStatsFiltered(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"where", Options{Where: "OBJECTID < 3"}},
+		{"bbox", Options{BBox: &orb.Bound{Min: orb.Point{-1, -1}, Max: orb.Point{11, 11}}, Where: "OBJECTID <> 3"}},
+	}
+	for _, test := range tests {
+		_, report, err := ConvertWithReport([]byte(statsData), test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := report.Stats
+		expected := map[string]int{"multipoint": 1, "polyline": 1}
+		if stats.InputFeatures != 4 || !reflect.DeepEqual(stats.InputGeometryTypes, expected) {
+			t.Errorf("%s: expected 4 input features and types %v, got %d and %v", test.name, expected, stats.InputFeatures, stats.InputGeometryTypes)
+		}
+		if stats.InputVertices != 4 || stats.RingsDropped != 0 || stats.HolesPromoted != 0 {
+			t.Errorf("%s: expected only the vertices of kept features, got %+v", test.name, stats)
+		}
+	}
+
+	// the polygon's rings are converted before the spatial filter drops it
+	_, report, err := ConvertWithReport([]byte(statsData), Options{BBox: &orb.Bound{Min: orb.Point{100, 100}, Max: orb.Point{101, 101}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := report.Stats; stats.RingsDropped != 0 || stats.HolesPromoted != 0 || stats.InputVertices != 0 {
+		t.Errorf("expected no stats for features outside the bbox, got %+v", stats)
+	}
+}
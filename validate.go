@@ -0,0 +1,397 @@
+package arcgis2geojson
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/paulmach/orb"
+)
+
+// kinds of geometry issues
+const (
+	IssueInvalidCoordinate = "invalid-coordinate"
+	IssueOutOfRange        = "out-of-range"
+	IssueUnclosedRing      = "unclosed-ring"
+	IssueDegenerateRing    = "degenerate-ring"
+	IssueDuplicateVertex   = "duplicate-vertex"
+	IssueSpike             = "spike"
+	IssueSelfIntersection  = "self-intersection"
+	IssueHoleOutsideShell  = "hole-outside-shell"
+)
+
+// Issue is a problem with a geometry
+type Issue struct {
+	// Feature is the index in the input of the feature with the problem
+	Feature int `json:"feature"`
+	// ID is the id of the converted feature, if it has one
+	ID   interface{} `json:"id,omitempty"`
+	Kind string      `json:"kind"`
+	// Path locates the problem. paths starting with geometry or rings point into the input feature,
+	// paths starting with coordinates into the converted geometry.
+	Path string `json:"path"`
+	// Point is where the problem is, when it has a valid location
+	Point   []float64 `json:"point,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Validate converts arcgis json as configured by opts and checks the converted geometries. it also
+// reports the unclosed and degenerate rings of the input, which the conversion closes or drops.
+func Validate(data []byte, opts Options) ([]Issue, error) {
+	arcgisJSON, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newConverter(arcgisJSON, opts)
+	if err != nil {
+		return nil, err
+	}
+	issues := []Issue{}
+	for i, f := range arcgisJSON.Features {
+		if !c.keepFeature(f) {
+			continue
+		}
+		if path, err := checkGeometry(f); err != nil {
+			issues = append(issues, Issue{Feature: i, Kind: IssueInvalidCoordinate, Path: path, Message: err.Error()})
+			continue
+		}
+		feature := c.featureToFeature(f, i)
+		if feature == nil || !c.keepGeometry(feature) {
+			continue
+		}
+		featureIssues := validateInputRings(f)
+		if feature.Geometry != nil {
+			featureIssues = append(featureIssues, ValidateGeometry(feature.Geometry)...)
+		}
+		for _, issue := range featureIssues {
+			issue.Feature = i
+			issue.ID = feature.ID
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// featureRings returns the rings a feature is converted from and their json path
+func featureRings(f ArcGISFeature) ([]Ring, string) {
+	if len(f.Geometry.Rings) != 0 {
+		return f.Geometry.Rings, "geometry.rings"
+	}
+	return f.Rings, "rings"
+}
+
+// validateInputRings reports the rings of a feature that the conversion closes or drops. the points of
+// the rings have been checked by checkGeometry.
+func validateInputRings(f ArcGISFeature) []Issue {
+	issues := []Issue{}
+	if _, ok := featureEnvelope(f); ok {
+		// the envelope replaces the rings
+		return issues
+	}
+	rings, path := featureRings(f)
+	for i, ring := range rings {
+		ringPath := fmt.Sprintf("%s[%d]", path, i)
+		points := len(ring)
+		if points > 0 && !pointsEqual(ring[0][:2], ring[points-1][:2]) {
+			issues = append(issues, Issue{Kind: IssueUnclosedRing, Path: ringPath, Point: ring[0][:2],
+				Message: "ring is not closed, the conversion closes it"})
+			points++
+		}
+		if points < minRingPoints {
+			issue := Issue{Kind: IssueDegenerateRing, Path: ringPath,
+				Message: fmt.Sprintf("ring has %d points, fewer than 4, and is dropped by the conversion", len(ring))}
+			if len(ring) > 0 {
+				issue.Point = ring[0][:2]
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// ValidateGeometry checks a geometry for invalid and out of range coordinates, unclosed and degenerate
+// rings, duplicate vertices, spikes, self-intersecting rings and holes outside their shell. the
+// Feature of the issues is left zero.
+func ValidateGeometry(g orb.Geometry) []Issue {
+	issues := []Issue{}
+	switch t := g.(type) {
+	case orb.Point:
+		issues = append(issues, validatePoints("coordinates", []orb.Point{t}, false)...)
+	case orb.MultiPoint:
+		issues = append(issues, validatePoints("coordinates", t, true)...)
+	case orb.LineString:
+		issues = append(issues, validatePath("coordinates", t)...)
+	case orb.MultiLineString:
+		for i, ls := range t {
+			issues = append(issues, validatePath(fmt.Sprintf("coordinates[%d]", i), ls)...)
+		}
+	case orb.Polygon:
+		issues = append(issues, validatePolygon("coordinates", t)...)
+	case orb.MultiPolygon:
+		for i, p := range t {
+			issues = append(issues, validatePolygon(fmt.Sprintf("coordinates[%d]", i), p)...)
+		}
+	case orb.Collection:
+		for i, c := range t {
+			for _, issue := range ValidateGeometry(c) {
+				issue.Path = fmt.Sprintf("geometries[%d].%s", i, issue.Path)
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+func issuePoint(pt orb.Point) []float64 {
+	return []float64{pt[0], pt[1]}
+}
+
+func validCoordinate(pt orb.Point) bool {
+	return !math.IsNaN(pt[0]) && !math.IsNaN(pt[1]) && !math.IsInf(pt[0], 0) && !math.IsInf(pt[1], 0)
+}
+
+// validatePoints checks the coordinates of points. indexed adds the index of each point to the path.
+func validatePoints(path string, points []orb.Point, indexed bool) []Issue {
+	issues := []Issue{}
+	for i, pt := range points {
+		pointPath := path
+		if indexed {
+			pointPath = fmt.Sprintf("%s[%d]", path, i)
+		}
+		switch {
+		case !validCoordinate(pt):
+			issues = append(issues, Issue{Kind: IssueInvalidCoordinate, Path: pointPath,
+				Message: fmt.Sprintf("coordinate %v is not a number", pt)})
+		case pt[0] < -180 || pt[0] > 180 || pt[1] < -90 || pt[1] > 90:
+			issues = append(issues, Issue{Kind: IssueOutOfRange, Path: pointPath, Point: issuePoint(pt),
+				Message: fmt.Sprintf("coordinate %v is outside -180..180, -90..90", pt)})
+		}
+	}
+	return issues
+}
+
+// validatePath checks the points of a line or ring for invalid coordinates, duplicate vertices and spikes
+func validatePath(path string, points []orb.Point) []Issue {
+	issues := validatePoints(path, points, true)
+	for _, issue := range issues {
+		if issue.Kind == IssueInvalidCoordinate {
+			return issues
+		}
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i] == points[i-1] {
+			issues = append(issues, Issue{Kind: IssueDuplicateVertex, Path: fmt.Sprintf("%s[%d]", path, i),
+				Point: issuePoint(points[i]), Message: "vertex repeats the previous vertex"})
+		}
+	}
+	for _, i := range spikes(points) {
+		issues = append(issues, Issue{Kind: IssueSpike, Path: fmt.Sprintf("%s[%d]", path, i),
+			Point: issuePoint(points[i]), Message: "path turns back on itself at this vertex"})
+	}
+	return issues
+}
+
+// distinctVertices returns the indexes of the vertices of a path, skipping repeated points and the
+// closing point of a ring
+func distinctVertices(points []orb.Point, ring bool) []int {
+	indexes := []int{}
+	for i := range points {
+		if i > 0 && points[i] == points[i-1] {
+			continue
+		}
+		if ring && i == len(points)-1 && len(indexes) > 0 && points[i] == points[indexes[0]] {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// spikes returns the indexes of vertices where a path turns back on itself, going around the closing
+// point of rings
+func spikes(points []orb.Point) []int {
+	ring := len(points) > 2 && points[0] == points[len(points)-1]
+	vertices := distinctVertices(points, ring)
+	n := len(vertices)
+	result := []int{}
+	if n < 3 {
+		return result
+	}
+	for k := 0; k < n; k++ {
+		if !ring && (k == 0 || k == n-1) {
+			continue
+		}
+		prev := points[vertices[(k+n-1)%n]]
+		pt := points[vertices[k]]
+		next := points[vertices[(k+1)%n]]
+		a, b := sub(pt, prev), sub(next, pt)
+		if cross(a, b) == 0 && a[0]*b[0]+a[1]*b[1] < 0 {
+			result = append(result, vertices[k])
+		}
+	}
+	return result
+}
+
+// validateRing checks a ring for everything a path is checked for, and for being closed, having an
+// area and not crossing itself
+func validateRing(path string, r orb.Ring) []Issue {
+	issues := validatePath(path, r)
+	for _, issue := range issues {
+		if issue.Kind == IssueInvalidCoordinate {
+			return issues
+		}
+	}
+	if len(r) == 0 {
+		return append(issues, Issue{Kind: IssueDegenerateRing, Path: path, Message: "ring is empty"})
+	}
+	if !r.Closed() {
+		issues = append(issues, Issue{Kind: IssueUnclosedRing, Path: path, Point: issuePoint(r[0]),
+			Message: "first and last points of the ring differ"})
+	}
+	if len(r) < minRingPoints {
+		return append(issues, Issue{Kind: IssueDegenerateRing, Path: path, Point: issuePoint(r[0]),
+			Message: fmt.Sprintf("ring has %d points, fewer than 4", len(r))})
+	}
+	crossings := selfIntersections(r)
+	for _, pt := range crossings {
+		issues = append(issues, Issue{Kind: IssueSelfIntersection, Path: path, Point: issuePoint(pt),
+			Message: fmt.Sprintf("ring crosses or touches itself at %v", pt)})
+	}
+	if len(crossings) == 0 && r.Orientation() == 0 {
+		issues = append(issues, Issue{Kind: IssueDegenerateRing, Path: path, Point: issuePoint(r[0]),
+			Message: "ring has no area"})
+	}
+	return issues
+}
+
+// selfIntersections returns the distinct points where non-adjacent edges of a ring meet
+func selfIntersections(r orb.Ring) []orb.Point {
+	vertices := distinctVertices(r, true)
+	n := len(vertices)
+	edges := make([]edge, n)
+	for k := range vertices {
+		edges[k] = edge{r[vertices[k]], r[vertices[(k+1)%n]]}
+	}
+	pairs := [][2]int{}
+	overlappingEdges(edges, func(i, j int) {
+		if j == i+1 || (i == 0 && j == n-1) {
+			// adjacent, or adjacent through the closing point
+			return
+		}
+		pairs = append(pairs, [2]int{i, j})
+	})
+	// in the order of the edges, whatever order the sweep found them in
+	sort.Slice(pairs, func(a, b int) bool {
+		return pairs[a][0] < pairs[b][0] || (pairs[a][0] == pairs[b][0] && pairs[a][1] < pairs[b][1])
+	})
+	points := []orb.Point{}
+	seen := map[orb.Point]bool{}
+	for _, pair := range pairs {
+		if pt, ok := segmentIntersection(edges[pair[0]], edges[pair[1]]); ok && !seen[pt] {
+			seen[pt] = true
+			points = append(points, pt)
+		}
+	}
+	return points
+}
+
+// overlappingEdges calls fn, with i < j, for every pair of edges whose bounds overlap. the edges are
+// swept in order of their smallest x, so only edges whose x ranges overlap are compared.
+func overlappingEdges(edges []edge, fn func(i, j int)) {
+	bounds := make([]orb.Bound, len(edges))
+	order := make([]int, len(edges))
+	for i, e := range edges {
+		bounds[i] = edgeBound(e)
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return bounds[order[a]].Min[0] < bounds[order[b]].Min[0] })
+	for k, i := range order {
+		for _, j := range order[k+1:] {
+			if bounds[j].Min[0] > bounds[i].Max[0] {
+				break
+			}
+			if !bounds[i].Intersects(bounds[j]) {
+				continue
+			}
+			if i < j {
+				fn(i, j)
+			} else {
+				fn(j, i)
+			}
+		}
+	}
+}
+
+// segmentIntersection returns a point where two segments meet, if they do
+func segmentIntersection(p, q edge) (orb.Point, bool) {
+	if !edgeBound(p).Intersects(edgeBound(q)) {
+		return orb.Point{}, false
+	}
+	r, s := sub(p[1], p[0]), sub(q[1], q[0])
+	qp := sub(q[0], p[0])
+	denom := cross(r, s)
+	if denom == 0 {
+		if cross(qp, r) != 0 {
+			return orb.Point{}, false
+		}
+		// collinear, they meet if an end of one lies on the other
+		for _, pt := range []orb.Point{q[0], q[1]} {
+			if pt == p[0] || pt == p[1] || onEdge(p, pt) {
+				return pt, true
+			}
+		}
+		for _, pt := range []orb.Point{p[0], p[1]} {
+			if onEdge(q, pt) {
+				return pt, true
+			}
+		}
+		return orb.Point{}, false
+	}
+	t := cross(qp, s) / denom
+	u := cross(qp, r) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return orb.Point{}, false
+	}
+	return orb.Point{p[0][0] + t*r[0], p[0][1] + t*r[1]}, true
+}
+
+// validatePolygon checks the rings of a polygon and that its holes are inside its shell
+func validatePolygon(path string, p orb.Polygon) []Issue {
+	issues := []Issue{}
+	for i, r := range p {
+		issues = append(issues, validateRing(fmt.Sprintf("%s[%d]", path, i), r)...)
+	}
+	if len(p) == 0 {
+		return issues
+	}
+	shell := toCoordinates(p[0])
+	for i, hole := range p[1:] {
+		for _, pt := range hole {
+			if !validCoordinate(pt) {
+				break
+			}
+			if !coordinatesContainPoint(shell, []float64{pt[0], pt[1]}) && !onRing(p[0], pt) {
+				issues = append(issues, Issue{Kind: IssueHoleOutsideShell, Path: fmt.Sprintf("%s[%d]", path, i+1),
+					Point: issuePoint(pt), Message: "hole is not inside the outer ring"})
+				break
+			}
+		}
+	}
+	return issues
+}
+
+// onRing reports whether a point lies on the boundary of a ring
+func onRing(r orb.Ring, pt orb.Point) bool {
+	for i := 1; i < len(r); i++ {
+		e := edge{r[i-1], r[i]}
+		if pt == e[0] || pt == e[1] || (cross(sub(pt, e[0]), sub(e[1], e[0])) == 0 && onEdge(e, pt)) {
+			return true
+		}
+	}
+	return false
+}
+
+// edgeBound returns the bounds of an edge
+func edgeBound(e edge) orb.Bound {
+	return orb.Bound{Min: e[0], Max: e[0]}.Extend(e[1])
+}
@@ -0,0 +1,106 @@
+package arcgis2geojson
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestValidateGeometry(t *testing.T) {
+	square := orb.Ring{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	tests := []struct {
+		name     string
+		g        orb.Geometry
+		expected []string
+	}{
+		{"valid polygon", orb.Polygon{square, {{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}}}, nil},
+		{"valid line", orb.LineString{{0, 0}, {1, 1}, {2, 0}}, nil},
+		{"nan", orb.Point{math.NaN(), 0}, []string{IssueInvalidCoordinate}},
+		{"inf", orb.LineString{{0, 0}, {math.Inf(1), 0}}, []string{IssueInvalidCoordinate}},
+		{"out of range", orb.MultiPoint{{0, 0}, {0, 91}}, []string{IssueOutOfRange}},
+		{"duplicate vertex", orb.LineString{{0, 0}, {1, 1}, {1, 1}, {2, 0}}, []string{IssueDuplicateVertex}},
+		{"spike", orb.LineString{{0, 0}, {2, 0}, {1, 0}}, []string{IssueSpike}},
+		{"ring spike", orb.Polygon{{{0, 0}, {10, 0}, {10, 10}, {10, 20}, {10, 10}, {0, 10}, {0, 0}}}, []string{IssueSpike, IssueSelfIntersection}},
+		{"unclosed", orb.Polygon{{{0, 0}, {10, 0}, {10, 10}, {0, 10}}}, []string{IssueUnclosedRing}},
+		{"too few points", orb.Polygon{{{0, 0}, {10, 0}, {0, 0}}}, []string{IssueDegenerateRing}},
+		{"no area", orb.Polygon{{{0, 0}, {5, 0}, {10, 0}, {0, 0}}}, []string{IssueSpike, IssueSpike, IssueDegenerateRing}},
+		{"bow tie", orb.Polygon{{{0, 0}, {10, 10}, {10, 0}, {0, 10}, {0, 0}}}, []string{IssueSelfIntersection}},
+		{"hole outside", orb.Polygon{square, {{20, 20}, {20, 21}, {21, 21}, {20, 20}}}, []string{IssueHoleOutsideShell}},
+		{"hole touching the shell", orb.Polygon{square, {{0, 5}, {5, 6}, {5, 4}, {0, 5}}}, nil},
+	}
+	for _, test := range tests {
+		kinds := []string{}
+		for _, issue := range ValidateGeometry(test.g) {
+			kinds = append(kinds, issue.Kind)
+		}
+		if len(test.expected) == 0 {
+			test.expected = []string{}
+		}
+		if !reflect.DeepEqual(kinds, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, kinds)
+		}
+	}
+
+	issues := ValidateGeometry(orb.MultiPolygon{{square}, {{{0, 0}, {10, 10}, {10, 0}, {0, 10}, {0, 0}}}})
+	if len(issues) != 1 || issues[0].Path != "coordinates[1][0]" || !reflect.DeepEqual(issues[0].Point, []float64{5, 5}) {
+		t.Errorf("expected a self-intersection at coordinates[1][0] [5 5], got %v", issues)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1}, "geometry": {"rings": [[[0, 0], [0, 10], [10, 10], [10, 0]], [[1, 1], [2, 2]]]}},
+			{"attributes": {"OBJECTID": 2}, "geometry": {"paths": [[[0, 0], [1, 1], [2, 2]]]}},
+			{"attributes": {"OBJECTID": 3}, "geometry": {"paths": [[[0, 0], [1, 1], [1, 1]]]}}
+		]
+	}`
+	issues, err := Validate([]byte(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Issue{
+		{Feature: 0, ID: 1.0, Kind: IssueUnclosedRing, Path: "geometry.rings[0]"},
+		{Feature: 0, ID: 1.0, Kind: IssueUnclosedRing, Path: "geometry.rings[1]"},
+		{Feature: 0, ID: 1.0, Kind: IssueDegenerateRing, Path: "geometry.rings[1]"},
+		{Feature: 2, ID: 3.0, Kind: IssueDuplicateVertex, Path: "coordinates[2]"},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		e := expected[i]
+		id, _ := toFloat64(issue.ID)
+		if issue.Feature != e.Feature || id != e.ID || issue.Kind != e.Kind || issue.Path != e.Path {
+			t.Errorf("issue %d: expected %v, got %v", i, e, issue)
+		}
+	}
+
+	issues, err = Validate([]byte(data), Options{Where: "OBJECTID = 2"})
+	if err != nil || len(issues) != 0 {
+		t.Errorf("expected the where clause to leave no issues, got %v %v", issues, err)
+	}
+}
+
+// circle returns a counterclockwise ring of n vertices around a circle
+func circle(n int) orb.Ring {
+	r := make(orb.Ring, n+1)
+	for i := 0; i < n; i++ {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		r[i] = orb.Point{10 * math.Cos(a), 10 * math.Sin(a)}
+	}
+	r[n] = r[0]
+	return r
+}
+
+func BenchmarkValidateGeometry(b *testing.B) {
+	p := orb.Polygon{circle(20000)}
+	for i := 0; i < b.N; i++ {
+		if issues := ValidateGeometry(p); len(issues) != 0 {
+			b.Fatal(issues)
+		}
+	}
+}
@@ -0,0 +1,839 @@
+package arcgis2geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Where is a parsed arcgis where clause. it supports the sql-92 subset used by arcgis services:
+// comparisons (=, <>, !=, <, <=, >, >=), [NOT] IN, [NOT] LIKE with an optional ESCAPE, [NOT] BETWEEN,
+// IS [NOT] NULL, AND, OR, NOT and parentheses, over operands built with +, -, *, /, ||, the functions
+// in expr.go other than those of the geometry and DATE 'yyyy-mm-dd hh:mm:ss' literals.
+type Where struct {
+	clause string
+	root   whereNode
+}
+
+// ParseWhere parses a where clause
+func ParseWhere(clause string) (*Where, error) {
+	tokens, err := lexWhere(clause)
+	if err != nil {
+		return nil, fmt.Errorf("error: invalid where clause: %v", err)
+	}
+	p := &whereParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err == nil {
+		err = p.expectEOF()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error: invalid where clause: %v", err)
+	}
+	return &Where{clause: clause, root: root}, nil
+}
+
+// String returns the where clause as it was parsed
+func (w *Where) String() string {
+	return w.clause
+}
+
+// Match reports whether attributes satisfy the where clause. comparisons with null are unknown,
+// and unknown does not match.
+func (w *Where) Match(attributes map[string]interface{}) bool {
+	return w.root.eval(&record{attributes: attributes}) == whereTrue
+}
+
+///////////////////////////////////////////////////////////////////////////////////////
+// lexer
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenKeyword
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var whereKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IN": true, "LIKE": true, "ESCAPE": true,
+	"BETWEEN": true, "IS": true, "NULL": true, "DATE": true, "TIMESTAMP": true,
+}
+
+func lexWhere(s string) ([]token, error) {
+	tokens := []token{}
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'':
+			// string literal, '' escapes a quote
+			var sb strings.Builder
+			j := i + 1
+			for {
+				if j >= len(rs) {
+					return nil, fmt.Errorf("unterminated string at position %d", i)
+				}
+				if rs[j] == '\'' {
+					if j+1 < len(rs) && rs[j+1] == '\'' {
+						sb.WriteRune('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteRune(rs[j])
+				j++
+			}
+			tokens = append(tokens, token{tokenString, sb.String(), i})
+			i = j + 1
+		case r == '"' || r == '[':
+			// quoted identifier
+			end := '"'
+			if r == '[' {
+				end = ']'
+			}
+			j := i + 1
+			for j < len(rs) && rs[j] != end {
+				j++
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("unterminated identifier at position %d", i)
+			}
+			tokens = append(tokens, token{tokenIdent, string(rs[i+1 : j]), i})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == 'e' || rs[j] == 'E' ||
+				((rs[j] == '-' || rs[j] == '+') && (rs[j-1] == 'e' || rs[j-1] == 'E'))) {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, string(rs[i:j]), i})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '.') {
+				j++
+			}
+			text := string(rs[i:j])
+			if whereKeywords[strings.ToUpper(text)] {
+				tokens = append(tokens, token{tokenKeyword, strings.ToUpper(text), i})
+			} else {
+				tokens = append(tokens, token{tokenIdent, text, i})
+			}
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"||", "<>", "!=", "<=", ">=", "=", "<", ">", "(", ")", ",", "+", "-", "*", "/"} {
+				if strings.HasPrefix(string(rs[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at position %d", r, i)
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i += len(op)
+		}
+	}
+	tokens = append(tokens, token{tokenEOF, "end of clause", len(rs)})
+	return tokens, nil
+}
+
+///////////////////////////////////////////////////////////////////////////////////////
+// parser
+
+type whereParser struct {
+	tokens []token
+	i      int
+	// geometry allows functions of the geometry, which expressions have and where clauses don't
+	geometry bool
+}
+
+func (p *whereParser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *whereParser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given keyword or operator
+func (p *whereParser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokenKeyword || t.kind == tokenOperator) && t.text == text {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *whereParser) expect(text string) error {
+	if !p.accept(text) {
+		t := p.peek()
+		return fmt.Errorf("expected %s, got %q at position %d", text, t.text, t.pos)
+	}
+	return nil
+}
+
+func (p *whereParser) expectEOF() error {
+	if t := p.peek(); t.kind != tokenEOF {
+		return fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+	return nil
+}
+
+func (p *whereParser) parseOr() (whereNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseAnd() (whereNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseNot() (whereNode, error) {
+	if p.accept("NOT") {
+		n, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *whereParser) parsePredicate() (whereNode, error) {
+	// a parenthesis either groups a condition or starts an operand, try the condition first
+	if t := p.peek(); t.kind == tokenOperator && t.text == "(" {
+		start := p.i
+		p.next()
+		n, err := p.parseOr()
+		if err == nil && p.accept(")") && !p.continuesOperand() {
+			return n, nil
+		}
+		p.i = start
+	}
+
+	left, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	switch {
+	case t.kind == tokenOperator && isComparison(t.text):
+		p.next()
+		right, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return compareNode{t.text, left, right}, nil
+	case p.accept("IS"):
+		not := p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		var n whereNode = isNullNode{left}
+		if not {
+			n = notNode{n}
+		}
+		return n, nil
+	}
+
+	not := p.accept("NOT")
+	var n whereNode
+	switch {
+	case p.accept("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		values := []valueNode{}
+		for {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		n = inNode{left, values}
+	case p.accept("LIKE"):
+		pattern, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		escape := ""
+		if p.accept("ESCAPE") {
+			t := p.next()
+			if t.kind != tokenString || len([]rune(t.text)) != 1 {
+				return nil, fmt.Errorf("escape must be a single character at position %d", t.pos)
+			}
+			escape = t.text
+		}
+		like := likeNode{value: left, pattern: pattern, escape: escape}
+		if literal, ok := pattern.(literalNode); ok && literal.v != nil {
+			like.re = likeToRegexp(whereString(literal.v), escape)
+		}
+		n = like
+	case p.accept("BETWEEN"):
+		low, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		n = andNode{compareNode{">=", left, low}, compareNode{"<=", left, high}}
+	default:
+		t := p.peek()
+		return nil, fmt.Errorf("expected a condition, got %q at position %d", t.text, t.pos)
+	}
+	if not {
+		n = notNode{n}
+	}
+	return n, nil
+}
+
+// continuesOperand reports whether the next token continues an operand rather than ending a condition
+func (p *whereParser) continuesOperand() bool {
+	t := p.peek()
+	switch t.kind {
+	case tokenOperator:
+		return t.text != ")" && t.text != ","
+	case tokenKeyword:
+		switch t.text {
+		case "IN", "LIKE", "BETWEEN", "IS":
+			return true
+		case "NOT":
+			next := p.tokens[p.i+1]
+			return next.text == "IN" || next.text == "LIKE" || next.text == "BETWEEN"
+		}
+	}
+	return false
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "=", "<>", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// parseValue parses an arithmetic or concatenation expression of literals, fields and functions
+func (p *whereParser) parseValue() (valueNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenOperator || (t.text != "+" && t.text != "-" && t.text != "||") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmeticNode{t.text, left, right}
+	}
+}
+
+func (p *whereParser) parseTerm() (valueNode, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenOperator || (t.text != "*" && t.text != "/") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmeticNode{t.text, left, right}
+	}
+}
+
+func (p *whereParser) parseFactor() (valueNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return literalNode{i}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at position %d", t.text, t.pos)
+		}
+		return literalNode{f}, nil
+	case tokenString:
+		return literalNode{t.text}, nil
+	case tokenIdent:
+		if p.accept("(") {
+			name := strings.ToUpper(t.text)
+			f, ok := expressionFunctions[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown function %q at position %d", t.text, t.pos)
+			}
+			if geometryFunctions[name] && !p.geometry {
+				return nil, fmt.Errorf("%s at position %d needs the geometry, which where clauses don't have", name, t.pos)
+			}
+			args := []valueNode{}
+			if !p.accept(")") {
+				for {
+					arg, err := p.parseValue()
+					if err != nil {
+						return nil, err
+					}
+					args = append(args, arg)
+					if !p.accept(",") {
+						break
+					}
+				}
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+			}
+			if len(args) < f.minArgs || (f.maxArgs >= 0 && len(args) > f.maxArgs) {
+				return nil, fmt.Errorf("wrong number of arguments to %s at position %d", name, t.pos)
+			}
+			return funcNode{name, args}, nil
+		}
+		return fieldNode{t.text}, nil
+	case tokenKeyword:
+		switch t.text {
+		case "NULL":
+			return literalNode{nil}, nil
+		case "DATE", "TIMESTAMP":
+			s := p.next()
+			if s.kind != tokenString {
+				return nil, fmt.Errorf("expected a date string at position %d", s.pos)
+			}
+			ms, err := parseWhereDate(s.text)
+			if err != nil {
+				return nil, fmt.Errorf("bad date %q at position %d", s.text, s.pos)
+			}
+			return literalNode{ms}, nil
+		}
+	case tokenOperator:
+		switch t.text {
+		case "(":
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return v, nil
+		case "-":
+			v, err := p.parseFactor()
+			if err != nil {
+				return nil, err
+			}
+			if l, ok := v.(literalNode); ok {
+				if i, ok := l.v.(int64); ok {
+					return literalNode{-i}, nil
+				}
+			}
+			return arithmeticNode{"-", literalNode{0.0}, v}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+}
+
+// parseWhereDate parses a date literal to epoch milliseconds, the way arcgis stores dates
+func parseWhereDate(s string) (float64, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02", "2006-01-02T15:04:05Z07:00", "1/2/2006 15:04:05", "1/2/2006"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return float64(t.UnixNano() / int64(time.Millisecond)), nil
+		}
+	}
+	return 0, fmt.Errorf("error: unknown date format %q", s)
+}
+
+///////////////////////////////////////////////////////////////////////////////////////
+// evaluation
+
+// sql three valued logic
+type whereResult int
+
+const (
+	whereFalse whereResult = iota
+	whereTrue
+	whereUnknown
+)
+
+func whereBool(b bool) whereResult {
+	if b {
+		return whereTrue
+	}
+	return whereFalse
+}
+
+type whereNode interface {
+	eval(r *record) whereResult
+}
+
+type valueNode interface {
+	value(r *record) interface{}
+}
+
+type orNode struct{ left, right whereNode }
+
+func (n orNode) eval(r *record) whereResult {
+	left, right := n.left.eval(r), n.right.eval(r)
+	if left == whereTrue || right == whereTrue {
+		return whereTrue
+	}
+	if left == whereUnknown || right == whereUnknown {
+		return whereUnknown
+	}
+	return whereFalse
+}
+
+type andNode struct{ left, right whereNode }
+
+func (n andNode) eval(r *record) whereResult {
+	left, right := n.left.eval(r), n.right.eval(r)
+	if left == whereFalse || right == whereFalse {
+		return whereFalse
+	}
+	if left == whereUnknown || right == whereUnknown {
+		return whereUnknown
+	}
+	return whereTrue
+}
+
+type notNode struct{ n whereNode }
+
+func (n notNode) eval(r *record) whereResult {
+	switch n.n.eval(r) {
+	case whereTrue:
+		return whereFalse
+	case whereFalse:
+		return whereTrue
+	}
+	return whereUnknown
+}
+
+type isNullNode struct{ v valueNode }
+
+func (n isNullNode) eval(r *record) whereResult {
+	return whereBool(n.v.value(r) == nil)
+}
+
+type compareNode struct {
+	op          string
+	left, right valueNode
+}
+
+func (n compareNode) eval(r *record) whereResult {
+	c, ok := compareValues(n.left.value(r), n.right.value(r))
+	if !ok {
+		return whereUnknown
+	}
+	switch n.op {
+	case "=":
+		return whereBool(c == 0)
+	case "<>", "!=":
+		return whereBool(c != 0)
+	case "<":
+		return whereBool(c < 0)
+	case "<=":
+		return whereBool(c <= 0)
+	case ">":
+		return whereBool(c > 0)
+	case ">=":
+		return whereBool(c >= 0)
+	}
+	return whereUnknown
+}
+
+type inNode struct {
+	v      valueNode
+	values []valueNode
+}
+
+func (n inNode) eval(r *record) whereResult {
+	v := n.v.value(r)
+	result := whereFalse
+	for _, candidate := range n.values {
+		c, ok := compareValues(v, candidate.value(r))
+		if !ok {
+			result = whereUnknown
+			continue
+		}
+		if c == 0 {
+			return whereTrue
+		}
+	}
+	return result
+}
+
+type likeNode struct {
+	value   valueNode
+	pattern valueNode
+	escape  string
+
+	// compiled pattern, when the pattern is a literal
+	re *regexp.Regexp
+}
+
+func (n likeNode) eval(r *record) whereResult {
+	v, p := n.value.value(r), n.pattern.value(r)
+	if v == nil || p == nil {
+		return whereUnknown
+	}
+	re := n.re
+	if re == nil {
+		re = likeToRegexp(whereString(p), n.escape)
+	}
+	return whereBool(re.MatchString(whereString(v)))
+}
+
+// likeToRegexp converts a like pattern, where % matches any run of characters and _ any single character
+func likeToRegexp(pattern, escape string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case escape != "" && string(r) == escape:
+			escaped = true
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+type literalNode struct{ v interface{} }
+
+func (n literalNode) value(r *record) interface{} {
+	return n.v
+}
+
+type fieldNode struct{ name string }
+
+// value looks up the field by name, falling back to a case insensitive match like sql does. when several
+// fields match case insensitively, the first in sorted order is used.
+func (n fieldNode) value(r *record) interface{} {
+	if v, ok := r.attributes[n.name]; ok {
+		return whereValue(v)
+	}
+	match := ""
+	for k := range r.attributes {
+		if strings.EqualFold(k, n.name) && (match == "" || k < match) {
+			match = k
+		}
+	}
+	if match == "" {
+		return nil
+	}
+	return whereValue(r.attributes[match])
+}
+
+type funcNode struct {
+	name string
+	args []valueNode
+}
+
+func (n funcNode) value(r *record) interface{} {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.value(r)
+	}
+	return finite(expressionFunctions[n.name].call(r, args))
+}
+
+type arithmeticNode struct {
+	op          string
+	left, right valueNode
+}
+
+// value applies the operator to two numbers. + and || concatenate when either operand is a string.
+// like sql, the result is null when either operand is null, when dividing by zero and when it overflows.
+func (n arithmeticNode) value(r *record) interface{} {
+	lv, rv := n.left.value(r), n.right.value(r)
+	if lv == nil || rv == nil {
+		return nil
+	}
+	_, lString := lv.(string)
+	_, rString := rv.(string)
+	if n.op == "||" || (n.op == "+" && (lString || rString)) {
+		return whereString(lv) + whereString(rv)
+	}
+	left, lok := toFloat64(lv)
+	right, rok := toFloat64(rv)
+	if !lok || !rok {
+		return nil
+	}
+	switch n.op {
+	case "+":
+		return finite(left + right)
+	case "-":
+		return finite(left - right)
+	case "*":
+		return finite(left * right)
+	case "/":
+		if right == 0 {
+			return nil
+		}
+		return finite(left / right)
+	}
+	return nil
+}
+
+// finite returns null for a float that is NaN or infinite, which json can't hold, and v otherwise
+func finite(v interface{}) interface{} {
+	if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return nil
+	}
+	return v
+}
+
+// whereValue normalizes attribute values to nil, int64, float64, string or bool. integers stay int64 so
+// that ids beyond 2^53 compare exactly.
+func whereValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, string, bool, int64, float64:
+		return t
+	case int:
+		return int64(t)
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+	}
+	if f, ok := toFloat64(v); ok {
+		return f
+	}
+	return fmt.Sprint(v)
+}
+
+func whereString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case int64:
+		return strconv.FormatInt(t, 10)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// whereInt returns an int64 or an integer string as an int64
+func whereInt(v interface{}) (int64, bool) {
+	switch t := v.(type) {
+	case int64:
+		return t, true
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// compareValues compares two values numerically when both are numbers, or one is a number and the other
+// a numeric string, and as strings otherwise. numbers are compared as int64 when both are integers, and
+// as float64 otherwise. it returns false if either value is null.
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	_, aInt := a.(int64)
+	_, bInt := b.(int64)
+	_, aFloat := a.(float64)
+	_, bFloat := b.(float64)
+	if aInt || bInt || aFloat || bFloat {
+		ai, aok := whereInt(a)
+		bi, bok := whereInt(b)
+		if aok && bok {
+			switch {
+			case ai < bi:
+				return -1, true
+			case ai > bi:
+				return 1, true
+			}
+			return 0, true
+		}
+		af, aok := toFloat64(a)
+		bf, bok := toFloat64(b)
+		if aok && bok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return strings.Compare(whereString(a), whereString(b)), true
+}
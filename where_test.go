@@ -0,0 +1,137 @@
+package arcgis2geojson
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWhere(t *testing.T) {
+	attributes := map[string]interface{}{
+		"OBJECTID":      json.Number("48772"),
+		"CTYNAME":       "RENTON",
+		"PROP_NAME":     "BOEING VACANT LAND",
+		"ZIP5":          "98057",
+		"LOTSQFT":       json.Number("101141"),
+		"KCA_ACRES":     json.Number("2.32"),
+		"POSTALCTYNAME": nil,
+		"Shape.area":    101139.1,
+	}
+	tests := []struct {
+		clause   string
+		expected bool
+	}{
+		{"1=1", true},
+		{"CTYNAME = 'RENTON'", true},
+		{"ctyname <> 'RENTON'", false},
+		{"OBJECTID > 48000 AND LOTSQFT <= 101141", true},
+		{"ZIP5 = 98057", true},
+		{"CTYNAME IN ('SEATTLE', 'RENTON')", true},
+		{"CTYNAME NOT IN ('SEATTLE', 'RENTON')", false},
+		{"PROP_NAME LIKE 'BOEING%'", true},
+		{"PROP_NAME LIKE '_OEING%LAND'", true},
+		{"PROP_NAME NOT LIKE '%SEA%'", true},
+		{"UPPER(PROP_NAME) LIKE LOWER('%vacant%')", false},
+		{"KCA_ACRES BETWEEN 2 AND 3", true},
+		{"KCA_ACRES NOT BETWEEN 2 AND 3", false},
+		{"POSTALCTYNAME IS NULL", true},
+		{"POSTALCTYNAME IS NOT NULL", false},
+		{"POSTALCTYNAME = 'RENTON'", false},
+		{"NOT POSTALCTYNAME = 'RENTON'", false},
+		{"POSTALCTYNAME = 'RENTON' OR CTYNAME = 'RENTON'", true},
+		{"(CTYNAME = 'SEATTLE' OR ZIP5 = '98057') AND NOT (LOTSQFT < 1000)", true},
+		{"LOTSQFT / 43560 > 2", true},
+		{"(LOTSQFT + 1) * 2 = 202284", true},
+		{"\"Shape.area\" > 100000", true},
+		{"MISSING = 1", false},
+		{"PROP_NAME = 'O''NEIL'", false},
+	}
+	for _, test := range tests {
+		where, err := ParseWhere(test.clause)
+		if err != nil {
+			t.Errorf("%s: %v", test.clause, err)
+			continue
+		}
+		if where.Match(attributes) != test.expected {
+			t.Errorf("%s: expected %v", test.clause, test.expected)
+		}
+	}
+}
+
+func TestWhereLargeIntegers(t *testing.T) {
+	// 2^53 + 1 rounds to 2^53 as a float64
+	attributes := map[string]interface{}{"ID": json.Number("9007199254740993")}
+	tests := []struct {
+		clause   string
+		expected bool
+	}{
+		{"ID = 9007199254740993", true},
+		{"ID = 9007199254740992", false},
+		{"ID > 9007199254740992", true},
+		{"ID = '9007199254740993'", true},
+		{"ID IN (9007199254740992, 9007199254740993)", true},
+	}
+	for _, test := range tests {
+		where, err := ParseWhere(test.clause)
+		if err != nil {
+			t.Errorf("%s: %v", test.clause, err)
+			continue
+		}
+		if where.Match(attributes) != test.expected {
+			t.Errorf("%s: expected %v", test.clause, test.expected)
+		}
+	}
+}
+
+func TestWhereFieldCase(t *testing.T) {
+	attributes := map[string]interface{}{"name": "exact", "NAME": "upper", "Name": "title"}
+	for _, test := range []struct{ clause, expected string }{
+		{"name = 'exact'", "name"},
+		{"Name = 'title'", "Name"},
+		{"nAmE = 'upper'", "sorted first"},
+	} {
+		for i := 0; i < 10; i++ {
+			where, err := ParseWhere(test.clause)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !where.Match(attributes) {
+				t.Errorf("%s: expected a match on %s", test.clause, test.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestWhereInvalid(t *testing.T) {
+	for _, clause := range []string{
+		"",
+		"CTYNAME =",
+		"CTYNAME = 'RENTON",
+		"CTYNAME IN 'RENTON'",
+		"(CTYNAME = 'RENTON'",
+		"CTYNAME = 'RENTON' ZIP5",
+		"KCA_ACRES BETWEEN 2",
+		"CTYNAME ~ 'R'",
+		"FOO(CTYNAME) = 1",
+		"AREA() > 10",
+		"ROUND(CENTROID_X(), 2) = 1",
+	} {
+		if _, err := ParseWhere(clause); err == nil {
+			t.Errorf("%q: expected error", clause)
+		}
+	}
+}
+
+func TestConvertWhere(t *testing.T) {
+	data := `{
+		"spatialReference": {"wkid": 4326},
+		"features": [
+			{"attributes": {"OBJECTID": 1, "COUNTY": "KING"}, "geometry": {"points": [[1, 2]]}},
+			{"attributes": {"OBJECTID": 2, "COUNTY": "PIERCE"}, "geometry": {"points": [[3, 4]]}}
+		]
+	}`
+	fc := convertFeatureCollection(t, data, Options{Where: "COUNTY = 'PIERCE'"})
+	if len(fc.Features) != 1 || fc.Features[0].ID != 2.0 {
+		t.Errorf("expected only feature 2, got %v", fc.Features)
+	}
+}